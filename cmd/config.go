@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
 )
 
 // Represents an entire config file
@@ -58,6 +60,15 @@ type AtlantisProject struct {
 
 	// Atlantis uses DependsOn to define dependencies between projects
 	DependsOn []string `json:"depends_on,omitempty"`
+
+	// Warnings about how the project was generated, written as comments next to its `dir`
+	warnings []string
+
+	// Set on projects read from the old config with `--preserve-projects`, until they are updated
+	preserved bool
+
+	// Name of the environment of `--env-matrix` the project was generated for
+	env string
 }
 
 // Autoplan settings for which plans affect other plans
@@ -88,3 +99,47 @@ func readOldConfig() (*AtlantisConfig, error) {
 
 	return &config, nil
 }
+
+// annotateWarnings adds the warnings of each project as a comment to the `dir` line of that project in
+// `yamlString`, which is the marshalled config holding `projects` in the same order. The lines are found
+// from the parsed document, so the formatting of `yamlString` is kept.
+func annotateWarnings(yamlString string, projects []AtlantisProject) (string, error) {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(yamlString), &document); err != nil {
+		return "", err
+	}
+	projectNodes := mappingValue(&document, "projects")
+	if projectNodes == nil || projectNodes.Kind != yamlv3.SequenceNode {
+		return yamlString, nil
+	}
+
+	lines := strings.Split(yamlString, "\n")
+	for index, projectNode := range projectNodes.Content {
+		if index >= len(projects) || len(projects[index].warnings) == 0 {
+			continue
+		}
+		dirNode := mappingValue(projectNode, "dir")
+		if dirNode == nil || dirNode.Line < 1 || dirNode.Line > len(lines) {
+			continue
+		}
+		lines[dirNode.Line-1] += " # WARNING: " + strings.ReplaceAll(strings.Join(projects[index].warnings, "; "), "\n", " ")
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// mappingValue returns the value of `key` in the mapping `node`, or of the mapping of the document `node`
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) == 1 {
+		node = node.Content[0]
+	}
+	if node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// Name of the config file that is picked up from the `--root` directory when `--config` is not given
+const toolConfigFileName = ".terragrunt-atlantis-config.yaml"
+
+// Prefix of the environment variables that set flags, such as TERRAGRUNT_ATLANTIS_CONFIG_CASCADE_DEPTH
+const toolConfigEnvPrefix = "TERRAGRUNT_ATLANTIS_CONFIG_"
+
+// Path of the config file given with `--config`
+var toolConfigPath string
+
+// Flags that can't be set from the config file, or from the environment
+var toolConfigIgnoredFlags = map[string]bool{
+	"help":   true,
+	"config": true,
+}
+
+// loadToolConfig sets every flag that was not given on the command line. Environment variables take
+// precedence over the config file, which takes precedence over the flag defaults.
+func loadToolConfig(flags *pflag.FlagSet) error {
+	projectRules = nil
+	setFromEnv := map[string]bool{}
+
+	var envErr error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if envErr != nil || flag.Changed || toolConfigIgnoredFlags[flag.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(flagEnvName(flag.Name))
+		if !ok {
+			return
+		}
+		if err := setFlagValue(flag, envFlagValues(flag, value)); err != nil {
+			envErr = fmt.Errorf("invalid value for %s: %w", flagEnvName(flag.Name), err)
+			return
+		}
+		setFromEnv[flag.Name] = true
+	})
+	if envErr != nil {
+		return envErr
+	}
+	warnUnknownEnvVars(flags)
+
+	// `--config` itself can come from the environment, while the default path depends on `--root`
+	if value, ok := os.LookupEnv(flagEnvName("config")); ok && !flags.Changed("config") {
+		toolConfigPath = value
+	}
+	path := toolConfigPath
+	if path == "" {
+		path = filepath.Join(gitRoot, toolConfigFileName)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+
+	settings, err := readToolConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == projectRulesKey {
+			rules, err := decodeProjectRules(settings[name])
+			if err != nil {
+				return fmt.Errorf("invalid config file %s: %w", path, err)
+			}
+			projectRules = rules
+			continue
+		}
+
+		flag := flags.Lookup(name)
+		if flag == nil || toolConfigIgnoredFlags[name] {
+			return fmt.Errorf("invalid config file %s: %s", path, describeUnknownKey(name, toolConfigKeys(flags)))
+		}
+		if flag.Changed || setFromEnv[name] {
+			continue
+		}
+
+		values, err := configValueToStrings(flag, settings[name])
+		if err == nil {
+			err = setFlagValue(flag, values)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %s: %w", path, name, err)
+		}
+	}
+
+	log.Info("Read settings from ", path)
+	return nil
+}
+
+// readToolConfigFile reads the config file at `path` into a map of flag names to values
+func readToolConfigFile(path string) (map[string]interface{}, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(bytes, &settings); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// flagEnvName returns the name of the environment variable for a flag
+func flagEnvName(name string) string {
+	return toolConfigEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// toolConfigKeys returns all keys of the config file: the rules, and the names of all flags
+func toolConfigKeys(flags *pflag.FlagSet) []string {
+	keys := []string{projectRulesKey}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if !toolConfigIgnoredFlags[flag.Name] {
+			keys = append(keys, flag.Name)
+		}
+	})
+	return keys
+}
+
+// warnUnknownEnvVars logs a warning for environment variables with the prefix that don't belong to any flag
+func warnUnknownEnvVars(flags *pflag.FlagSet) {
+	known := map[string]bool{flagEnvName("config"): true}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		known[flagEnvName(flag.Name)] = true
+	})
+
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, toolConfigEnvPrefix) && !known[name] {
+			log.Warnf("Ignoring environment variable %s, which does not belong to any flag", name)
+		}
+	}
+}
+
+// configValueToStrings checks the type of a value from the config file against the flag it sets, and
+// converts it to the strings the flag parses
+func configValueToStrings(flag *pflag.Flag, value interface{}) ([]string, error) {
+	flagType := flag.Value.Type()
+
+	switch v := value.(type) {
+	case bool:
+		if flagType == "bool" {
+			return []string{strconv.FormatBool(v)}, nil
+		}
+	case float64:
+		if (flagType == "int" || flagType == "int64") && v == math.Trunc(v) {
+			return []string{strconv.FormatInt(int64(v), 10)}, nil
+		}
+	case string:
+		if flagType == "string" || flagType == "stringSlice" || flagType == "stringArray" {
+			return []string{v}, nil
+		}
+	case []interface{}:
+		if flagType == "stringSlice" || flagType == "stringArray" {
+			values := make([]string, 0, len(v))
+			for i, element := range v {
+				str, ok := element.(string)
+				if !ok {
+					return nil, fmt.Errorf("must be a list of strings, but position %d is %v", i, element)
+				}
+				values = append(values, str)
+			}
+			return values, nil
+		}
+	}
+
+	expected := map[string]string{
+		"bool":        "a bool",
+		"int":         "a whole number",
+		"int64":       "a whole number",
+		"string":      "a string",
+		"stringSlice": "a list of strings",
+		"stringArray": "a list of strings",
+	}[flagType]
+	return nil, fmt.Errorf("must be %s, got %v", expected, value)
+}
+
+// setFlagValue sets a flag to the given values, which are parsed like on the command line. Lists replace the
+// default instead of being appended to it.
+// envFlagValues returns the values of `flag` in the environment variable `value`. Slice flags take comma
+// separated values, like on the command line, while string array flags such as `--set-env` take the whole
+// variable as a single value, as their values can contain commas.
+func envFlagValues(flag *pflag.Flag, value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	if flag.Value.Type() == "stringSlice" {
+		return strings.Split(value, ",")
+	}
+	return []string{value}
+}
+
+func setFlagValue(flag *pflag.Flag, values []string) error {
+	if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+		return sliceValue.Replace(values)
+	}
+	return flag.Value.Set(strings.Join(values, ","))
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a flag set with one flag of every type used by `generate`
+func newTestFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Bool("autoplan", false, "")
+	flags.Int("cascade-depth", -1, "")
+	flags.Int64("num-executors", 15, "")
+	flags.String("workflow", "", "")
+	flags.StringSlice("filter", []string{"default"}, "")
+	flags.StringArray("set-env", []string{}, "")
+	flags.String("config", "", "")
+	return flags
+}
+
+func writeToolConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), toolConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestFlagEnvName(t *testing.T) {
+	assert.Equal(t, "TERRAGRUNT_ATLANTIS_CONFIG_CASCADE_DEPTH", flagEnvName("cascade-depth"))
+	assert.Equal(t, "TERRAGRUNT_ATLANTIS_CONFIG_AUTOPLAN", flagEnvName("autoplan"))
+}
+
+func TestLoadToolConfig(t *testing.T) {
+	oldPath := toolConfigPath
+	defer func() { toolConfigPath = oldPath }()
+
+	t.Run("precedence", func(t *testing.T) {
+		flags := newTestFlagSet()
+		toolConfigPath = writeToolConfig(t, `
+autoplan: true
+workflow: fromFile
+cascade-depth: 2
+filter:
+  - a
+  - b
+`)
+		t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_WORKFLOW", "fromEnv")
+		t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_CASCADE_DEPTH", "3")
+		require.NoError(t, flags.Parse([]string{"--cascade-depth=4"}))
+
+		require.NoError(t, loadToolConfig(flags))
+
+		autoplan, _ := flags.GetBool("autoplan")
+		workflow, _ := flags.GetString("workflow")
+		depth, _ := flags.GetInt("cascade-depth")
+		filter, _ := flags.GetStringSlice("filter")
+		executors, _ := flags.GetInt64("num-executors")
+		assert.True(t, autoplan)
+		assert.Equal(t, "fromEnv", workflow)
+		assert.Equal(t, 4, depth)
+		assert.Equal(t, []string{"a", "b"}, filter)
+		assert.Equal(t, int64(15), executors)
+	})
+
+	t.Run("lists from the environment replace the default", func(t *testing.T) {
+		flags := newTestFlagSet()
+		toolConfigPath = writeToolConfig(t, "")
+		t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_FILTER", "x,y")
+
+		require.NoError(t, loadToolConfig(flags))
+		filter, _ := flags.GetStringSlice("filter")
+		assert.Equal(t, []string{"x", "y"}, filter)
+	})
+
+	t.Run("string arrays from the environment keep commas", func(t *testing.T) {
+		flags := newTestFlagSet()
+		toolConfigPath = writeToolConfig(t, "")
+		t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_SET_ENV", "LIST=a,b")
+
+		require.NoError(t, loadToolConfig(flags))
+		setEnv, _ := flags.GetStringArray("set-env")
+		assert.Equal(t, []string{"LIST=a,b"}, setEnv)
+	})
+
+	t.Run("config path from the environment", func(t *testing.T) {
+		flags := newTestFlagSet()
+		toolConfigPath = ""
+		t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_CONFIG", writeToolConfig(t, "workflow: fromEnvFile\n"))
+
+		require.NoError(t, loadToolConfig(flags))
+		workflow, _ := flags.GetString("workflow")
+		assert.Equal(t, "fromEnvFile", workflow)
+	})
+
+	t.Run("invalid environment values", func(t *testing.T) {
+		flags := newTestFlagSet()
+		toolConfigPath = writeToolConfig(t, "")
+		t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_AUTOPLAN", "maybe")
+
+		err := loadToolConfig(flags)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid value for TERRAGRUNT_ATLANTIS_CONFIG_AUTOPLAN")
+	})
+
+	errorCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"unknown key", "workflw: x\n", `"workflw", did you mean "workflow"?`},
+		{"config key", "config: other.yaml\n", `"config", must be one of: rules, autoplan, cascade-depth`},
+		{"bool type", "autoplan: yes please\n", "autoplan: must be a bool, got yes please"},
+		{"whole numbers", "cascade-depth: 1.5\n", "cascade-depth: must be a whole number, got 1.5"},
+		{"string type", "workflow: 12\n", "workflow: must be a string, got 12"},
+		{"list elements", "filter: [a, 1]\n", "filter: must be a list of strings, but position 1 is 1"},
+		{"not a map", "- autoplan\n", "invalid config file"},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			toolConfigPath = writeToolConfig(t, c.content)
+
+			err := loadToolConfig(newTestFlagSet())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), toolConfigPath)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+
+	t.Run("missing default file", func(t *testing.T) {
+		oldRoot := gitRoot
+		defer func() { gitRoot = oldRoot }()
+		gitRoot = t.TempDir()
+		toolConfigPath = ""
+
+		assert.NoError(t, loadToolConfig(newTestFlagSet()))
+	})
+
+	t.Run("missing explicit file", func(t *testing.T) {
+		toolConfigPath = filepath.Join(t.TempDir(), "missing.yaml")
+		assert.Error(t, loadToolConfig(newTestFlagSet()))
+	})
+}
@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// dependencyKind describes why a module depends on some path
+type dependencyKind string
+
+const (
+	// The path of an `include` block
+	dependencyKindInclude dependencyKind = "include"
+
+	// A value of the `extra_atlantis_dependencies` local
+	dependencyKindExtra dependencyKind = "extra"
+
+	// The config path of a `dependency` or `dependencies` block
+	dependencyKindDependency dependencyKind = "dependency"
+
+	// Files of a local `terraform.source`, and the local modules it calls
+	dependencyKindSource dependencyKind = "source"
+
+	// Var files from the `extra_arguments` of the `terraform` block
+	dependencyKindVarFile dependencyKind = "var-file"
+
+	// Local modules called by terraform code next to the terragrunt config
+	dependencyKindLocalModule dependencyKind = "local-module"
+)
+
+// allDependencyKinds lists every kind of edge, in the order they are documented
+var allDependencyKinds = []dependencyKind{
+	dependencyKindInclude,
+	dependencyKindExtra,
+	dependencyKindDependency,
+	dependencyKindSource,
+	dependencyKindVarFile,
+	dependencyKindLocalModule,
+}
+
+// Kinds of edges that are inherited from, and followed into, dependencies when cascading.
+// Set from the `--cascade-edge-kinds` flag by validateCascadeEdgeKinds.
+var cascadedDependencyKinds = map[dependencyKind]bool{
+	dependencyKindInclude:     true,
+	dependencyKindExtra:       true,
+	dependencyKindDependency:  true,
+	dependencyKindSource:      true,
+	dependencyKindVarFile:     true,
+	dependencyKindLocalModule: true,
+}
+
+// validateCascadeEdgeKinds checks the `--cascade-edge-kinds` flag and stores it in cascadedDependencyKinds
+func validateCascadeEdgeKinds(kinds []string) error {
+	allowed := map[dependencyKind]bool{}
+	for _, kind := range kinds {
+		known := false
+		for _, validKind := range allDependencyKinds {
+			if dependencyKind(kind) == validKind {
+				known = true
+				break
+			}
+		}
+		if !known {
+			valid := make([]string, 0, len(allDependencyKinds))
+			for _, validKind := range allDependencyKinds {
+				valid = append(valid, string(validKind))
+			}
+			return fmt.Errorf("unknown cascade edge kind %q, must be one of: %s", kind, strings.Join(valid, ", "))
+		}
+		allowed[dependencyKind(kind)] = true
+	}
+
+	cascadedDependencyKinds = allowed
+	return nil
+}
+
+// cascades returns true if edges of this kind are picked up from dependencies when cascading
+func (k dependencyKind) cascades() bool {
+	return cascadedDependencyKinds[k]
+}
+
+// traversable returns true if cascading should look into the dependencies of the edge target.
+// Includes point at parent configs, and local modules are glob patterns, so neither of them is a module.
+func (k dependencyKind) traversable() bool {
+	return k != dependencyKindInclude && k != dependencyKindLocalModule
+}
+
+// A single direct dependency of a module
+type dependencyEdge struct {
+	// Absolute path (or glob pattern) the module depends on
+	path string
+
+	kind dependencyKind
+}
+
+// unlimitedCascadeDepth is the cascade depth of modules that cascade all the way down
+const unlimitedCascadeDepth = -1
+
+// globalCascadeDepth is the number of levels modules cascade into, unless overridden by `atlantis_cascade`
+func globalCascadeDepth() int {
+	if !cascadeDependencies {
+		return 0
+	}
+	if cascadeDepth < 0 {
+		return unlimitedCascadeDepth
+	}
+	return cascadeDepth
+}
+
+// moduleCascadeDepth is the number of levels the module described by `output` cascades into
+func moduleCascadeDepth(output getDependenciesOutput) int {
+	if output.cascadeDepth != nil {
+		return *output.cascadeDepth
+	}
+	return globalCascadeDepth()
+}
+
+// nextCascadeDepth is the depth left after cascading one level down
+func nextCascadeDepth(depth int) int {
+	if depth < 0 {
+		return unlimitedCascadeDepth
+	}
+	return depth - 1
+}
+
+// coversCascadeDepth returns true if cascading `have` levels reaches at least as deep as `want` levels
+func coversCascadeDepth(have int, want int) bool {
+	return have < 0 || (want >= 0 && have >= want)
+}
+
+// The memoization key of a closure
+type closureKey struct {
+	path  string
+	depth int
+
+	// The closure of the module a project is created for contains all its edges. Closures of modules
+	// cascaded into only contain the edges of kinds in cascadedDependencyKinds.
+	root bool
+}
+
+// dependencyGraph memoizes the transitive dependencies of modules. The direct edges of each module live in
+// getDependenciesCache, and have to be computed by `expand` before the closure of a module is requested.
+type dependencyGraph struct {
+	// Held for the whole of an expansion, so that a module is never seen as expanded while the modules
+	// it cascades into are still being parsed
+	expandMtx sync.Mutex
+
+	// The deepest cascade depth each module has been expanded with
+	expanded map[string]int
+
+	mtx      sync.Mutex
+	closures map[closureKey][]string
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{
+		expanded: map[string]int{},
+		closures: map[closureKey][]string{},
+	}
+}
+
+// A module waiting to be expanded, together with the number of levels left to cascade into
+type expansion struct {
+	path  string
+	depth int
+	root  bool
+}
+
+var moduleGraph = newDependencyGraph()
+
+// expand computes the direct dependencies of all `paths` and of every module they cascade into, as deep as
+// the cascade depth of each path requires. The modules of each layer are parsed concurrently.
+func (g *dependencyGraph) expand(ctx context.Context, paths []string) error {
+	g.expandMtx.Lock()
+	defer g.expandMtx.Unlock()
+
+	frontier := make([]expansion, 0, len(paths))
+	for _, path := range paths {
+		frontier = append(frontier, expansion{path: path, root: true})
+	}
+
+	workers := numExecutors
+	if workers < 1 {
+		workers = 1
+	}
+
+	for len(frontier) > 0 {
+		errGroup, _ := errgroup.WithContext(ctx)
+		sem := semaphore.NewWeighted(workers)
+
+		for _, item := range frontier {
+			// Cascading can point at globs, directories or files that do not exist. Those are leaves.
+			if !item.root && !isRegularFile(item.path) {
+				continue
+			}
+			if _, ok := getDependenciesCache.get(item.path); ok {
+				continue
+			}
+
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+
+			path := item.path
+			errGroup.Go(func() error {
+				defer sem.Release(1)
+
+				parsingContext, err := NewParsingContextWithConfigPath(ctx, path)
+				if err != nil {
+					getDependenciesCache.set(path, getDependenciesOutput{err: err})
+					return nil
+				}
+
+				// Errors are kept in the cache, and only reported by `getDependencies` for the module itself
+				getDirectDependencies(parsingContext, path)
+				return nil
+			})
+		}
+
+		if err := errGroup.Wait(); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		next := []expansion{}
+		queued := map[string]int{}
+		for _, item := range frontier {
+			output, _ := getDependenciesCache.get(item.path)
+
+			// A module picks its own depth when a project is created for it
+			if item.root {
+				item.depth = moduleCascadeDepth(output)
+			}
+
+			if previous, ok := g.expanded[item.path]; ok && coversCascadeDepth(previous, item.depth) {
+				continue
+			}
+			g.expanded[item.path] = item.depth
+
+			if item.depth == 0 || (!item.root && output.cascadeLeaf) {
+				continue
+			}
+
+			for _, edge := range output.dependencies {
+				if !edge.kind.traversable() || !edge.kind.cascades() {
+					continue
+				}
+
+				depth := nextCascadeDepth(item.depth)
+				if previous, ok := queued[edge.path]; ok {
+					if coversCascadeDepth(previous, depth) {
+						continue
+					}
+					// Replace the shallower expansion queued before
+					for i := range next {
+						if next[i].path == edge.path {
+							next[i].depth = depth
+						}
+					}
+					queued[edge.path] = depth
+					continue
+				}
+
+				queued[edge.path] = depth
+				next = append(next, expansion{path: edge.path, depth: depth})
+			}
+		}
+
+		frontier = next
+	}
+
+	return nil
+}
+
+// transitiveDependencies returns the direct dependencies of the module at `path`, each followed by its own
+// transitive dependencies, as deep as the cascade depth of the module. Every path appears once, at its
+// first occurrence.
+func (g *dependencyGraph) transitiveDependencies(path string) []string {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	output, _ := getDependenciesCache.get(path)
+	dependencies, _ := g.closure(closureKey{path, moduleCascadeDepth(output), true}, map[string]bool{})
+	return dependencies
+}
+
+// closure is the memoized depth first search behind transitiveDependencies. The boolean result is false if a
+// cycle cut the search short, in which case the result is not memoized.
+func (g *dependencyGraph) closure(key closureKey, visiting map[string]bool) ([]string, bool) {
+	if cached, ok := g.closures[key]; ok {
+		return cached, true
+	}
+
+	output, ok := getDependenciesCache.get(key.path)
+	if !ok || output.err != nil || (!key.root && output.cascadeLeaf) {
+		return nil, true
+	}
+
+	visiting[key.path] = true
+	defer delete(visiting, key.path)
+
+	complete := true
+	seen := make(map[string]bool, len(output.dependencies))
+	result := make([]string, 0, len(output.dependencies))
+	add := func(dep string) {
+		if !seen[dep] {
+			seen[dep] = true
+			result = append(result, dep)
+		}
+	}
+
+	for _, edge := range output.dependencies {
+		if !key.root && !edge.kind.cascades() {
+			continue
+		}
+		add(edge.path)
+
+		if key.depth == 0 || !edge.kind.traversable() || !edge.kind.cascades() {
+			continue
+		}
+		if visiting[edge.path] {
+			complete = false
+			continue
+		}
+
+		childDeps, childComplete := g.closure(closureKey{edge.path, nextCascadeDepth(key.depth), false}, visiting)
+		complete = complete && childComplete
+		for _, childDep := range childDeps {
+			add(childDep)
+		}
+	}
+
+	if complete {
+		g.closures[key] = result
+	}
+
+	return result, complete
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Replaces the global caches with a graph made from the given direct edges
+func setupTestGraph(t *testing.T, edges map[string][]dependencyEdge) {
+	oldCache, oldGraph, oldCascade, oldDepth, oldKinds := getDependenciesCache, moduleGraph, cascadeDependencies, cascadeDepth, cascadedDependencyKinds
+	t.Cleanup(func() {
+		getDependenciesCache, moduleGraph, cascadeDependencies, cascadeDepth, cascadedDependencyKinds = oldCache, oldGraph, oldCascade, oldDepth, oldKinds
+	})
+
+	getDependenciesCache = newGetDependenciesCache()
+	moduleGraph = newDependencyGraph()
+	cascadeDependencies = true
+	cascadeDepth = unlimitedCascadeDepth
+	for path, deps := range edges {
+		getDependenciesCache.set(path, getDependenciesOutput{dependencies: deps})
+	}
+}
+
+func TestTransitiveDependencies(t *testing.T) {
+	t.Run("orders dependencies depth first", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a": {{"/b", dependencyKindDependency}, {"/c", dependencyKindDependency}},
+			"/b": {{"/d", dependencyKindDependency}},
+			"/c": {{"/d", dependencyKindDependency}, {"/e", dependencyKindVarFile}},
+			"/d": {},
+		})
+
+		assert.Equal(t, []string{"/b", "/d", "/c", "/e"}, moduleGraph.transitiveDependencies("/a"))
+		assert.Equal(t, []string{"/d", "/e"}, moduleGraph.transitiveDependencies("/c"))
+	})
+
+	t.Run("does not traverse includes and local modules", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a":      {{"/parent", dependencyKindInclude}, {"/mod/*.tf*", dependencyKindLocalModule}},
+			"/parent": {{"/should-not-appear", dependencyKindExtra}},
+		})
+
+		assert.Equal(t, []string{"/parent", "/mod/*.tf*"}, moduleGraph.transitiveDependencies("/a"))
+	})
+
+	t.Run("does not cascade into sourceless configs", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a": {{"/env.hcl", dependencyKindExtra}},
+		})
+		getDependenciesCache.set("/env.hcl", getDependenciesOutput{
+			dependencies: []dependencyEdge{{"/should-not-appear", dependencyKindExtra}},
+			cascadeLeaf:  true,
+		})
+
+		assert.Equal(t, []string{"/env.hcl"}, moduleGraph.transitiveDependencies("/a"))
+		assert.Equal(t, []string{"/should-not-appear"}, moduleGraph.transitiveDependencies("/env.hcl"))
+	})
+
+	t.Run("skipped and failed modules add nothing", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a":       {{"/skipped", dependencyKindDependency}, {"/failed", dependencyKindDependency}},
+			"/skipped": nil,
+		})
+		getDependenciesCache.set("/failed", getDependenciesOutput{err: fmt.Errorf("broken")})
+
+		assert.Equal(t, []string{"/skipped", "/failed"}, moduleGraph.transitiveDependencies("/a"))
+	})
+
+	t.Run("cascading disabled", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a": {{"/b", dependencyKindDependency}},
+			"/b": {{"/c", dependencyKindDependency}},
+		})
+		cascadeDependencies = false
+
+		assert.Equal(t, []string{"/b"}, moduleGraph.transitiveDependencies("/a"))
+	})
+
+	t.Run("cycles terminate", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a": {{"/b", dependencyKindDependency}},
+			"/b": {{"/c", dependencyKindDependency}},
+			"/c": {{"/a", dependencyKindDependency}},
+		})
+
+		assert.Equal(t, []string{"/b", "/c", "/a"}, moduleGraph.transitiveDependencies("/a"))
+		assert.Equal(t, []string{"/c", "/a", "/b"}, moduleGraph.transitiveDependencies("/b"))
+		assert.Equal(t, []string{"/a", "/b", "/c"}, moduleGraph.transitiveDependencies("/c"))
+	})
+
+	t.Run("global cascade depth", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a": {{"/b", dependencyKindDependency}},
+			"/b": {{"/c", dependencyKindDependency}},
+			"/c": {{"/d", dependencyKindDependency}},
+			"/d": {},
+		})
+		cascadeDepth = 1
+
+		assert.Equal(t, []string{"/b", "/c"}, moduleGraph.transitiveDependencies("/a"))
+		assert.Equal(t, []string{"/c", "/d"}, moduleGraph.transitiveDependencies("/b"))
+	})
+
+	t.Run("module cascade depth overrides the global depth", func(t *testing.T) {
+		none, two := 0, 2
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/b": {{"/c", dependencyKindDependency}},
+			"/c": {{"/d", dependencyKindDependency}},
+			"/d": {{"/e", dependencyKindDependency}},
+			"/e": {},
+		})
+		getDependenciesCache.set("/none", getDependenciesOutput{dependencies: []dependencyEdge{{"/b", dependencyKindDependency}}, cascadeDepth: &none})
+		getDependenciesCache.set("/two", getDependenciesOutput{dependencies: []dependencyEdge{{"/b", dependencyKindDependency}}, cascadeDepth: &two})
+		cascadeDependencies = false
+
+		assert.Equal(t, []string{"/b"}, moduleGraph.transitiveDependencies("/none"))
+		assert.Equal(t, []string{"/b", "/c", "/d"}, moduleGraph.transitiveDependencies("/two"))
+
+		// The depth of a dependency does not change how far its dependers cascade
+		getDependenciesCache.set("/c", getDependenciesOutput{dependencies: []dependencyEdge{{"/d", dependencyKindDependency}}, cascadeDepth: &none})
+		moduleGraph = newDependencyGraph()
+		assert.Equal(t, []string{"/b", "/c", "/d"}, moduleGraph.transitiveDependencies("/two"))
+	})
+
+	t.Run("restricted edge kinds", func(t *testing.T) {
+		setupTestGraph(t, map[string][]dependencyEdge{
+			"/a": {{"/a.tfvars", dependencyKindVarFile}, {"/b", dependencyKindDependency}, {"/x", dependencyKindExtra}},
+			"/b": {{"/b.tfvars", dependencyKindVarFile}, {"/c", dependencyKindDependency}},
+			"/x": {{"/y", dependencyKindDependency}},
+			"/c": {},
+		})
+		require.NoError(t, validateCascadeEdgeKinds([]string{"dependency"}))
+
+		// The module itself keeps all of its edges, but only follows and inherits dependency blocks
+		assert.Equal(t, []string{"/a.tfvars", "/b", "/c", "/x"}, moduleGraph.transitiveDependencies("/a"))
+	})
+
+	t.Run("deep chains are memoized", func(t *testing.T) {
+		edges := map[string][]dependencyEdge{}
+		for i := 0; i < 500; i++ {
+			edges[fmt.Sprintf("/m%d", i)] = []dependencyEdge{{fmt.Sprintf("/m%d", i+1), dependencyKindDependency}}
+		}
+		setupTestGraph(t, edges)
+
+		assert.Len(t, moduleGraph.transitiveDependencies("/m0"), 500)
+		assert.Len(t, moduleGraph.closures, 500)
+		assert.Len(t, moduleGraph.transitiveDependencies("/m250"), 250)
+	})
+}
+
+func TestDependencyGraphExpand(t *testing.T) {
+	setupTestGraph(t, nil)
+
+	root, err := filepath.Abs(filepath.Join(testFixturesDir, "chained_dependencies"))
+	require.NoError(t, err)
+	depender := filepath.Join(root, "depender_on_depender", "terragrunt.hcl")
+
+	require.NoError(t, moduleGraph.expand(context.Background(), []string{depender}))
+
+	// Expanding a single module also parses everything it cascades into
+	for _, module := range []string{"depender", "dependency", "depender_on_depender/nested"} {
+		path := filepath.ToSlash(filepath.Join(root, module, "terragrunt.hcl"))
+		_, ok := getDependenciesCache.get(path)
+		assert.True(t, ok, "expected %s to be parsed", module)
+		assert.Contains(t, moduleGraph.expanded, path, "expected %s to be expanded", module)
+	}
+
+	assert.Equal(t, []string{
+		filepath.ToSlash(filepath.Join(root, "depender", "terragrunt.hcl")),
+		filepath.ToSlash(filepath.Join(root, "dependency", "terragrunt.hcl")),
+		filepath.ToSlash(filepath.Join(root, "depender_on_depender", "nested", "terragrunt.hcl")),
+	}, moduleGraph.transitiveDependencies(depender))
+}
+
+func TestDependencyGraphExpandDepth(t *testing.T) {
+	setupTestGraph(t, nil)
+	cascadeDepth = 0
+
+	root, err := filepath.Abs(filepath.Join(testFixturesDir, "chained_dependencies"))
+	require.NoError(t, err)
+	depender := filepath.Join(root, "depender_on_depender", "terragrunt.hcl")
+
+	require.NoError(t, moduleGraph.expand(context.Background(), []string{depender}))
+
+	// Without cascading, only the module itself is parsed
+	_, ok := getDependenciesCache.get(filepath.ToSlash(filepath.Join(root, "depender", "terragrunt.hcl")))
+	assert.False(t, ok)
+
+	// Expanding again with a deeper budget continues where the first expansion stopped
+	cascadeDepth = unlimitedCascadeDepth
+	moduleGraph.expanded = map[string]int{}
+	require.NoError(t, moduleGraph.expand(context.Background(), []string{depender}))
+	_, ok = getDependenciesCache.get(filepath.ToSlash(filepath.Join(root, "dependency", "terragrunt.hcl")))
+	assert.True(t, ok)
+}
+
+func TestValidateCascadeEdgeKinds(t *testing.T) {
+	setupTestGraph(t, nil)
+
+	require.NoError(t, validateCascadeEdgeKinds([]string{"dependency", "var-file"}))
+	assert.True(t, dependencyKindDependency.cascades())
+	assert.True(t, dependencyKindVarFile.cascades())
+	assert.False(t, dependencyKindExtra.cascades())
+
+	err := validateCascadeEdgeKinds([]string{"dependencies"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown cascade edge kind "dependencies"`)
+}
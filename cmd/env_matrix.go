@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/ghodss/yaml"
+)
+
+// Path of the file given with `--env-matrix`
+var envMatrixPath string
+
+// matrixEnv is one of the named sets of environment variables from `--env-matrix`. Every module is evaluated
+// once per set.
+type matrixEnv struct {
+	// Name of the set, which ends up in the names and workspaces of its projects
+	Name string `json:"name"`
+
+	// Environment variables that are set on top of the environment of the process
+	Env map[string]string `json:"env"`
+
+	// Workflow of the projects of this set, overriding `--workflow`. Locals still take precedence.
+	Workflow string `json:"workflow,omitempty"`
+}
+
+// envMatrixFile is the content of the `--env-matrix` file
+type envMatrixFile struct {
+	Environments []matrixEnv `json:"environments"`
+}
+
+// Names of environments have to be usable in project names and workspaces
+var validEnvName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// readEnvMatrix reads and validates the `--env-matrix` file at `path`
+func readEnvMatrix(path string) ([]matrixEnv, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decoded as JSON to reject unknown keys
+	encoded, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env matrix %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+
+	matrix := envMatrixFile{}
+	if err := decoder.Decode(&matrix); err != nil {
+		return nil, fmt.Errorf("invalid env matrix %s: %w", path, err)
+	}
+
+	if len(matrix.Environments) == 0 {
+		return nil, fmt.Errorf("invalid env matrix %s: environments must contain at least one environment", path)
+	}
+	seen := map[string]bool{}
+	for i, env := range matrix.Environments {
+		if !validEnvName.MatchString(env.Name) {
+			return nil, fmt.Errorf("invalid env matrix %s: environments[%d]: name %q must only contain letters, numbers, - and _", path, i, env.Name)
+		}
+		if seen[env.Name] {
+			return nil, fmt.Errorf("invalid env matrix %s: environments[%d]: name %q is used more than once", path, i, env.Name)
+		}
+		seen[env.Name] = true
+	}
+
+	return matrix.Environments, nil
+}
+
+type matrixEnvKey struct{}
+
+// withMatrixEnv returns a context in which modules are evaluated with the environment variables of `env`
+func withMatrixEnv(ctx context.Context, env *matrixEnv) context.Context {
+	if env == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, matrixEnvKey{}, env)
+}
+
+// matrixEnvFromContext returns the environment of the `--env-matrix` that `ctx` evaluates modules in, or nil
+func matrixEnvFromContext(ctx context.Context) *matrixEnv {
+	env, _ := ctx.Value(matrixEnvKey{}).(*matrixEnv)
+	return env
+}
+
+// wrapEnvError names the environment of `--env-matrix` in the error of a module, as every module is evaluated
+// once per environment
+func wrapEnvError(ctx context.Context, err error) error {
+	if env := matrixEnvFromContext(ctx); env != nil {
+		return fmt.Errorf("in environment %s: %w", env.Name, err)
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Writes an env matrix file to a temporary directory, returning its path
+func writeTestEnvMatrix(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "env_matrix.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestReadEnvMatrix(t *testing.T) {
+	envs, err := readEnvMatrix(writeTestEnvMatrix(t, `
+environments:
+  - name: prod
+    workflow: prod
+    env:
+      TG_ENV: prod
+  - name: staging
+    env:
+      TG_ENV: staging
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []matrixEnv{
+		{Name: "prod", Workflow: "prod", Env: map[string]string{"TG_ENV": "prod"}},
+		{Name: "staging", Env: map[string]string{"TG_ENV": "staging"}},
+	}, envs)
+
+	errorCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"unknown key", "environments:\n  - name: prod\n    enviroment: {}\n", `unknown field "enviroment"`},
+		{"no environments", "environments: []\n", "environments must contain at least one environment"},
+		{"invalid name", "environments:\n  - name: prod/eu\n", `environments[0]: name "prod/eu" must only contain letters, numbers, - and _`},
+		{"missing name", "environments:\n  - env: {TG_ENV: prod}\n", `environments[0]: name "" must only contain`},
+		{"duplicate name", "environments:\n  - name: prod\n  - name: prod\n", `environments[1]: name "prod" is used more than once`},
+		{"number as value", "environments:\n  - name: prod\n    env: {PORT: 8080}\n", "invalid env matrix"},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := readEnvMatrix(writeTestEnvMatrix(t, c.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
+
+func TestParsingContextUsesMatrixEnv(t *testing.T) {
+	t.Setenv("TG_ENV", "from-process")
+	t.Setenv("OTHER", "kept")
+
+	env := &matrixEnv{Name: "prod", Env: map[string]string{"TG_ENV": "prod"}}
+	parsingContext, err := NewParsingContextWithConfigPath(withMatrixEnv(context.Background(), env), "/tmp/terragrunt.hcl")
+	require.NoError(t, err)
+
+	assert.Equal(t, "prod", parsingContext.ParsingContext.TerragruntOptions.Env["TG_ENV"])
+	assert.Equal(t, "kept", parsingContext.ParsingContext.TerragruntOptions.Env["OTHER"])
+}
+
+func TestWrapEnvError(t *testing.T) {
+	err := errors.New("broken")
+	assert.Equal(t, err, wrapEnvError(context.Background(), err))
+
+	ctx := withMatrixEnv(context.Background(), &matrixEnv{Name: "prod"})
+	wrapped := wrapEnvError(ctx, err)
+	assert.Equal(t, "in environment prod: broken", wrapped.Error())
+	assert.ErrorIs(t, wrapped, err)
+}
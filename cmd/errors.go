@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// moduleFailure is the error of a single module when running with `--keep-going`
+type moduleFailure struct {
+	// Path of the terragrunt config or project hcl file that failed
+	path string
+
+	err error
+}
+
+// moduleFailures collects the errors of all modules that failed when running with `--keep-going`
+type moduleFailures struct {
+	mtx      sync.Mutex
+	failures []moduleFailure
+}
+
+func (f *moduleFailures) add(path string, err error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.failures = append(f.failures, moduleFailure{path, err})
+}
+
+// sorted returns all failures ordered by path
+func (f *moduleFailures) sorted() []moduleFailure {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	sorted := append([]moduleFailure{}, f.failures...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].path < sorted[j].path })
+	return sorted
+}
+
+// collectDiagnostics finds all HCL diagnostics in the tree of wrapped errors below `err`
+func collectDiagnostics(err error) hcl.Diagnostics {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case hcl.Diagnostics:
+		return e
+	case *hcl.Diagnostic:
+		return hcl.Diagnostics{e}
+	case interface{ Unwrap() []error }:
+		diags := hcl.Diagnostics{}
+		for _, wrapped := range e.Unwrap() {
+			diags = append(diags, collectDiagnostics(wrapped)...)
+		}
+		return diags
+	case interface{ Unwrap() error }:
+		return collectDiagnostics(e.Unwrap())
+	}
+
+	return nil
+}
+
+// describeError renders an error for humans. Every HCL diagnostic is printed with its position and the
+// offending line of source code. Errors without diagnostics are printed as they are.
+func describeError(err error) string {
+	diags := collectDiagnostics(err)
+	if len(diags) == 0 {
+		return err.Error()
+	}
+
+	var sb strings.Builder
+	for i, diag := range diags {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		if diag.Subject != nil {
+			fmt.Fprintf(&sb, "%s:%d:%d: ", diag.Subject.Filename, diag.Subject.Start.Line, diag.Subject.Start.Column)
+		}
+		sb.WriteString(diag.Summary)
+		if diag.Detail != "" {
+			sb.WriteString("; ")
+			sb.WriteString(diag.Detail)
+		}
+		sb.WriteString("\n")
+
+		if diag.Subject != nil {
+			sb.WriteString(sourceSnippet(*diag.Subject))
+		}
+	}
+
+	return sb.String()
+}
+
+// sourceSnippet returns the first line of `rng`, with the range itself underlined. Returns an empty string
+// if the file can not be read.
+func sourceSnippet(rng hcl.Range) string {
+	file, err := os.Open(rng.Filename)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if line != rng.Start.Line {
+			continue
+		}
+
+		text := strings.TrimRight(scanner.Text(), "\r")
+		runes := []rune(text)
+
+		start := rng.Start.Column - 1
+		if start < 0 || start > len(runes) {
+			start = len(runes)
+		}
+		width := 1
+		if rng.End.Line == rng.Start.Line && rng.End.Column > rng.Start.Column {
+			width = rng.End.Column - rng.Start.Column
+		}
+
+		// Keep tabs in the indentation of the marker, so it lines up with the source line
+		indent := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, string(runes[:start]))
+
+		gutter := fmt.Sprintf("%5d | ", line)
+		return fmt.Sprintf("%s%s\n%s%s%s\n", gutter, text, strings.Repeat(" ", len(gutter)-2)+"| ", indent, strings.Repeat("^", width))
+	}
+
+	return ""
+}
+
+// firstLine returns the summary of an error, for use in the table of failures
+func firstLine(err error) string {
+	diags := collectDiagnostics(err)
+	if len(diags) > 0 {
+		return diags[0].Summary
+	}
+
+	summary, _, _ := strings.Cut(strings.TrimSpace(err.Error()), "\n")
+	return summary
+}
+
+// writeFailureSummary writes a table of all failed modules, followed by the full error of each of them.
+// Paths are shown relative to `root`.
+func writeFailureSummary(w io.Writer, root string, failures []moduleFailure) {
+	relativePath := func(path string) string {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return path
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	fmt.Fprintf(w, "\n%d module(s) failed:\n\n", len(failures))
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "MODULE\tERROR")
+	for _, failure := range failures {
+		fmt.Fprintf(table, "%s\t%s\n", relativePath(failure.path), firstLine(failure.err))
+	}
+	table.Flush()
+
+	for _, failure := range failures {
+		fmt.Fprintf(w, "\n%s:\n%s\n", relativePath(failure.path), strings.TrimRight(describeError(failure.err), "\n"))
+	}
+}
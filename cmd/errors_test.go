@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Parses HCL that is known to be broken, returning the diagnostics
+func brokenHclError(t *testing.T, content string) (string, error) {
+	path := filepath.Join(t.TempDir(), "terragrunt.hcl")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	_, err := parseHcl(hclparse.NewParser(), content, path)
+	require.Error(t, err)
+	return path, err
+}
+
+func TestCollectDiagnostics(t *testing.T) {
+	_, err := brokenHclError(t, "locals {\n  a = \"unterminated\n}\n")
+	diags := collectDiagnostics(err)
+	assert.NotEmpty(t, diags)
+
+	t.Run("wrapped errors", func(t *testing.T) {
+		wrapped := fmt.Errorf("while parsing: %w", err)
+		assert.Equal(t, diags, collectDiagnostics(wrapped))
+	})
+
+	t.Run("joined errors", func(t *testing.T) {
+		diag := &hcl.Diagnostic{Severity: hcl.DiagError, Summary: "other"}
+		joined := fmt.Errorf("two errors: %w, %w", err, diag)
+		assert.Len(t, collectDiagnostics(joined), len(diags)+1)
+	})
+
+	t.Run("plain errors", func(t *testing.T) {
+		assert.Empty(t, collectDiagnostics(fmt.Errorf("plain")))
+		assert.Empty(t, collectDiagnostics(nil))
+	})
+}
+
+func TestDescribeError(t *testing.T) {
+	t.Run("diagnostics get a source snippet", func(t *testing.T) {
+		path, err := brokenHclError(t, "locals {\n  a = = 1\n}\n")
+
+		description := describeError(err)
+		assert.Contains(t, description, path+":2:7: ")
+		assert.Contains(t, description, "    2 |   a = = 1\n")
+		assert.Contains(t, description, "      |       ^\n")
+	})
+
+	t.Run("plain errors", func(t *testing.T) {
+		assert.Equal(t, "plain", describeError(fmt.Errorf("plain")))
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		err := hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Broken",
+			Subject: &hcl.Range{
+				Filename: "/does/not/exist.hcl",
+				Start:    hcl.Pos{Line: 1, Column: 1},
+				End:      hcl.Pos{Line: 1, Column: 2},
+			},
+		}}
+		assert.Equal(t, "/does/not/exist.hcl:1:1: Broken\n", describeError(err))
+	})
+}
+
+func TestWriteFailureSummary(t *testing.T) {
+	failures := &moduleFailures{}
+	failures.add("/repo/b/terragrunt.hcl", fmt.Errorf("second failure\nwith details"))
+	failures.add("/repo/a/terragrunt.hcl", fmt.Errorf("first failure"))
+
+	var out bytes.Buffer
+	writeFailureSummary(&out, "/repo", failures.sorted())
+
+	assert.Equal(t, `
+2 module(s) failed:
+
+MODULE            ERROR
+a/terragrunt.hcl  first failure
+b/terragrunt.hcl  second failure
+
+a/terragrunt.hcl:
+first failure
+
+b/terragrunt.hcl:
+second failure
+with details
+`, out.String())
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Start from an empty environment instead of the one of the process, from `--clean-env`
+var cleanEnv bool
+
+// Dotenv files with environment variables, from `--env-file`
+var envFiles []string
+
+// Environment variables as NAME=VALUE, from `--set-env`
+var setEnvVars []string
+
+// The environment variables modules are evaluated with, set by `buildEvalEnv`. When nil, the environment of the
+// process is used.
+var evalEnv map[string]string
+
+// Names that are valid for environment variables
+var validEnvVarName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// buildEvalEnv builds the environment variables modules are evaluated with. Those of the process, unless
+// `--clean-env` is set, are overridden by the `--env-file` files in order, which are overridden by `--set-env`.
+func buildEvalEnv() (map[string]string, error) {
+	env := map[string]string{}
+	if !cleanEnv {
+		env = parseEnvironmentVariables()
+	}
+
+	for _, path := range envFiles {
+		values, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range values {
+			env[name] = value
+		}
+	}
+
+	for _, assignment := range setEnvVars {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok || !validEnvVarName.MatchString(name) {
+			return nil, fmt.Errorf("invalid --set-env %q, must be NAME=VALUE", assignment)
+		}
+		env[name] = value
+	}
+
+	return env, nil
+}
+
+// readEnvFile reads a dotenv file. Every line is a NAME=VALUE assignment, optionally starting with `export`.
+// Values can be quoted, where double quotes support escapes like \n. Empty lines and lines starting with #
+// are skipped, as are comments after unquoted values.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !validEnvVarName.MatchString(name) {
+			return nil, fmt.Errorf("invalid env file %s:%d: must be NAME=VALUE", path, lineNumber)
+		}
+
+		value, err = unquoteEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid env file %s:%d: %s: %w", path, lineNumber, name, err)
+		}
+		env[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// unquoteEnvValue returns the value of an assignment in a dotenv file
+func unquoteEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := closingQuote(value)
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		return strconv.Unquote(value[:end+1])
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		return value[1 : end+1], nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// closingQuote returns the position of the double quote closing the one that `value` starts with, or -1
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// missingEnvVars records the environment variables that `get_env` read while they were not set, with the
+// configs that read them
+type missingEnvVars struct {
+	mtx    sync.Mutex
+	byName map[string]map[string]bool
+}
+
+// Environment variables that were read by `get_env` while not set, during the current run
+var missingEnv = newMissingEnvVars()
+
+func newMissingEnvVars() *missingEnvVars {
+	return &missingEnvVars{byName: map[string]map[string]bool{}}
+}
+
+func (m *missingEnvVars) add(name string, path string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.byName[name] == nil {
+		m.byName[name] = map[string]bool{}
+	}
+	m.byName[name][path] = true
+}
+
+// sorted returns the names of the missing variables, each with the sorted paths of the configs that read it
+func (m *missingEnvVars) sorted() [][2]string {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	missing := make([][2]string, 0, len(m.byName))
+	for name, paths := range m.byName {
+		sortedPaths := make([]string, 0, len(paths))
+		for path := range paths {
+			sortedPaths = append(sortedPaths, path)
+		}
+		sort.Strings(sortedPaths)
+		missing = append(missing, [2]string{name, strings.Join(sortedPaths, ", ")})
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i][0] < missing[j][0] })
+	return missing
+}
+
+// reportMissingEnv logs every environment variable that `get_env` read while it was not set, as the output
+// then depends on the defaults in the configs, or fails, depending on where it runs
+func reportMissingEnv() {
+	for _, missing := range missingEnv.sorted() {
+		log.Warnf("Environment variable %s is not set, but read by get_env in %s", missing[0], missing[1])
+	}
+}
+
+// trackingGetEnv replaces the `get_env` function of terragrunt, to record the variables it reads that are not
+// set in `env`. `configPath` is the module being evaluated, which includes are reported as.
+func trackingGetEnv(env map[string]string, configPath string) function.Function {
+	return function.New(&function.Spec{
+		VarParam: &function.Parameter{Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if len(args) == 0 || len(args) > 2 {
+				return cty.StringVal(""), config.InvalidGetEnvParamsError{ActualNumParams: len(args), Example: `get_env("<NAME>", "[DEFAULT]")`}
+			}
+			params := make([]string, len(args))
+			for i, arg := range args {
+				params[i] = arg.AsString()
+			}
+
+			name := params[0]
+			if name == "" {
+				return cty.StringVal(""), config.InvalidEnvParamNameError{EnvName: name}
+			}
+			if value, ok := env[name]; ok {
+				return cty.StringVal(value), nil
+			}
+
+			missingEnv.add(name, relativeToRoot(configPath))
+			if len(params) == 1 {
+				return cty.StringVal(""), config.EnvVarNotFoundError{EnvVar: name}
+			}
+			return cty.StringVal(params[1]), nil
+		},
+	})
+}
+
+// relativeToRoot returns `path` relative to the root, or as it is when it is outside of the root
+func relativeToRoot(path string) string {
+	relative, err := filepath.Rel(gitRoot, path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return path
+	}
+	return filepath.ToSlash(relative)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Writes a dotenv file to a temporary directory, returning its path
+func writeTestEnvFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestReadEnvFile(t *testing.T) {
+	env, err := readEnvFile(writeTestEnvFile(t, `
+# comment
+PLAIN=value
+export EXPORTED=exported
+SPACED = spaced value # trailing comment
+DOUBLE="with \"escapes\"\n" # comment
+SINGLE='no \n escapes'
+HASH=a#b
+EMPTY=
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "exported",
+		"SPACED":   "spaced value",
+		"DOUBLE":   "with \"escapes\"\n",
+		"SINGLE":   `no \n escapes`,
+		"HASH":     "a#b",
+		"EMPTY":    "",
+	}, env)
+
+	errorCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"no assignment", "PLAIN\n", ":1: must be NAME=VALUE"},
+		{"invalid name", "# comment\n1NAME=value\n", ":2: must be NAME=VALUE"},
+		{"unclosed double quote", `NAME="value`, ":1: NAME: missing closing quote"},
+		{"unclosed single quote", `NAME='value`, ":1: NAME: missing closing quote"},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := readEnvFile(writeTestEnvFile(t, c.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
+
+func TestBuildEvalEnv(t *testing.T) {
+	resetForRun()
+	t.Setenv("FROM_PROCESS", "process")
+	t.Setenv("OVERRIDDEN", "process")
+	first := writeTestEnvFile(t, "OVERRIDDEN=first\nFROM_FILE=first\n")
+	second := writeTestEnvFile(t, "FROM_FILE=second\nSET=second\n")
+
+	envFiles = []string{first, second}
+	setEnvVars = []string{"SET=flag=with=equals"}
+	env, err := buildEvalEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "process", env["FROM_PROCESS"])
+	assert.Equal(t, "first", env["OVERRIDDEN"])
+	assert.Equal(t, "second", env["FROM_FILE"])
+	assert.Equal(t, "flag=with=equals", env["SET"])
+
+	cleanEnv = true
+	env, err = buildEvalEnv()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"OVERRIDDEN": "first", "FROM_FILE": "second", "SET": "flag=with=equals"}, env)
+
+	setEnvVars = []string{"NO_VALUE"}
+	_, err = buildEvalEnv()
+	assert.EqualError(t, err, `invalid --set-env "NO_VALUE", must be NAME=VALUE`)
+}
+
+func TestTrackingGetEnv(t *testing.T) {
+	resetForRun()
+	gitRoot = "/repo/"
+	missingEnv = newMissingEnvVars()
+
+	getEnv := trackingGetEnv(map[string]string{"SET": "value"}, "/repo/app/terragrunt.hcl")
+	otherGetEnv := trackingGetEnv(map[string]string{}, "/repo/db/terragrunt.hcl")
+
+	value, err := getEnv.Call([]cty.Value{cty.StringVal("SET")})
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("value"), value)
+
+	value, err = getEnv.Call([]cty.Value{cty.StringVal("MISSING"), cty.StringVal("default")})
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("default"), value)
+
+	_, err = otherGetEnv.Call([]cty.Value{cty.StringVal("MISSING")})
+	assert.ErrorContains(t, err, "Required environment variable MISSING - not found")
+
+	_, err = otherGetEnv.Call([]cty.Value{cty.StringVal("SET")})
+	assert.ErrorContains(t, err, "Required environment variable SET - not found")
+
+	_, err = getEnv.Call([]cty.Value{})
+	assert.Error(t, err)
+
+	assert.Equal(t, [][2]string{
+		{"MISSING", "app/terragrunt.hcl, db/terragrunt.hcl"},
+		{"SET", "db/terragrunt.hcl"},
+	}, missingEnv.sorted())
+}
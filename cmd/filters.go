@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"path/filepath"
+)
+
+// filterMatches returns the absolute paths matching the globs of `--filter`
+func filterMatches() ([]string, error) {
+	matches := []string{}
+	for _, filterPath := range filterPaths {
+		theseMatches, err := filepath.Glob(filterPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range theseMatches {
+			absoluteMatch, err := filepath.Abs(match)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, absoluteMatch)
+		}
+	}
+	return matches, nil
+}
+
+// filterWorkingPaths returns the paths to look for modules in from `dir`, a scan root or a project hcl dir. Without
+// `--filter`, that is all of `dir`. A project hcl dir is looked in entirely when a filter matches a directory
+// above it, and else only in the matches below it.
+func filterWorkingPaths(dir string) ([]string, error) {
+	if len(filterPaths) == 0 {
+		return []string{dir}, nil
+	}
+
+	matches, err := filterMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	workingPaths := []string{}
+	for _, match := range matches {
+		if isScanRoot(withTrailingSeparator(dir)) {
+			// With several scan roots, every match is only scanned once
+			if ownsPath(dir, match) {
+				workingPaths = append(workingPaths, match)
+			}
+			continue
+		}
+
+		if isWithin(dir, match) {
+			return []string{dir}, nil
+		}
+		if isWithin(match, dir) {
+			workingPaths = append(workingPaths, match)
+		}
+	}
+	return workingPaths, nil
+}
+
+// isSelectedByFilters returns whether the project of the project hcl dir `dir` is generated, as `--filter` is not
+// given or matches a path above or below `dir`. Its project covers all modules below it either way.
+func isSelectedByFilters(dir string) (bool, error) {
+	if len(filterPaths) == 0 {
+		return true, nil
+	}
+
+	matches, err := filterMatches()
+	if err != nil {
+		return false, err
+	}
+	for _, match := range matches {
+		if isWithin(match, dir) || isWithin(dir, match) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
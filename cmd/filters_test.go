@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterWorkingPaths(t *testing.T) {
+	defer func(filters []string, roots []string) {
+		filterPaths, scanRoots = filters, roots
+	}(filterPaths, scanRoots)
+
+	repo := t.TempDir()
+	for _, dir := range []string{"prod/app", "prod/db", "stage/app"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, dir), 0755))
+	}
+	scanRoots = []string{withTrailingSeparator(repo)}
+
+	tests := []struct {
+		name         string
+		filters      []string
+		dir          string
+		wantPaths    []string
+		wantSelected bool
+	}{
+		{
+			name:         "no filter",
+			dir:          filepath.Join(repo, "prod"),
+			wantPaths:    []string{filepath.Join(repo, "prod")},
+			wantSelected: true,
+		},
+		{
+			name:         "scan root",
+			filters:      []string{filepath.Join(repo, "*", "app")},
+			dir:          repo,
+			wantPaths:    []string{filepath.Join(repo, "prod", "app"), filepath.Join(repo, "stage", "app")},
+			wantSelected: true,
+		},
+		{
+			name:         "project hcl dir below a match",
+			filters:      []string{repo},
+			dir:          filepath.Join(repo, "prod"),
+			wantPaths:    []string{filepath.Join(repo, "prod")},
+			wantSelected: true,
+		},
+		{
+			name:         "project hcl dir above a match",
+			filters:      []string{filepath.Join(repo, "*", "app")},
+			dir:          filepath.Join(repo, "prod"),
+			wantPaths:    []string{filepath.Join(repo, "prod", "app")},
+			wantSelected: true,
+		},
+		{
+			name:         "project hcl dir not matched",
+			filters:      []string{filepath.Join(repo, "stage")},
+			dir:          filepath.Join(repo, "prod"),
+			wantPaths:    []string{},
+			wantSelected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filterPaths = tt.filters
+
+			paths, err := filterWorkingPaths(tt.dir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPaths, paths)
+
+			selected, err := isSelectedByFilters(tt.dir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSelected, selected)
+		})
+	}
+}
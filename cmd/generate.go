@@ -12,9 +12,10 @@ import (
 
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
-	"golang.org/x/sync/singleflight"
 
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -67,12 +68,24 @@ func makePathAbsolute(path string, parentPath string) string {
 	return result
 }
 
-var requestGroup singleflight.Group
-
-// Set up a cache for the getDependencies function
+// Set up a cache for the direct dependencies of every module
 type getDependenciesOutput struct {
-	dependencies []string
-	err          error
+	dependencies []dependencyEdge
+
+	// Number of levels this module cascades into, if overridden by the `atlantis_cascade` local
+	cascadeDepth *int
+
+	// Cleaned absolute paths of the configs the module includes, which makes them parents
+	includes []string
+
+	// Whether the module is a parent, if overridden by the `atlantis_parent` local
+	parentOverride *bool
+
+	// Whether the dependencies of the module are left out when cascading into it, as it is a parent from
+	// `atlantis_parent`, or else as it neither includes another config nor defines a terraform source
+	cascadeLeaf bool
+
+	err error
 }
 
 type GetDependenciesCache struct {
@@ -108,8 +121,9 @@ func cleanupCaches() {
 	parsedHclCache = sync.Map{}
 	parseLocalsCache = sync.Map{}
 
-	// Clear dependencies cache
+	// Clear dependencies cache and the graph built on top of it
 	getDependenciesCache = newGetDependenciesCache()
+	moduleGraph = newDependencyGraph()
 }
 
 func uniqueStrings(str []string) []string {
@@ -171,199 +185,285 @@ func sliceUnion(a, b []string) []string {
 	return result
 }
 
-// Parses the terragrunt config at `path` to find all modules it depends on
+// Parses the terragrunt config at `path` to find all modules it depends on, including the dependencies
+// of its dependencies when cascading is enabled
 func getDependencies(ctx *TerragruntParsingContext, path string) ([]string, error) {
-	res, err, _ := requestGroup.Do(path, func() (interface{}, error) {
-		// Check if this path has already been computed
-		cachedResult, ok := getDependenciesCache.get(path)
-		if ok {
-			return cachedResult.dependencies, cachedResult.err
-		}
+	if err := moduleGraph.expand(ctx.Context, []string{path}); err != nil {
+		return nil, err
+	}
 
-		// parse the module path to find what it includes, as well as its potential to be a parent
-		// return nils to indicate we should skip this project
-		isParent, includes, err := parseModule(ctx, path)
-		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
-		}
-		if isParent && ignoreParentTerragrunt {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, nil})
-			return nil, nil
-		}
+	output, _ := getDependenciesCache.get(path)
+	if output.err != nil {
+		return nil, output.err
+	}
 
-		dependencies := make([]string, 0, 8) // Pre-allocate with small capacity
-		if len(includes) > 0 {
-			for _, includeDep := range includes {
-				getDependenciesCache.set(includeDep.Path, getDependenciesOutput{nil, err})
-				dependencies = append(dependencies, includeDep.Path)
-			}
+	// nil edges are a sign from `getDirectDependencies` that this project should be skipped
+	if output.dependencies == nil {
+		return nil, nil
+	}
+
+	return moduleGraph.transitiveDependencies(path), nil
+}
+
+// Parses the terragrunt config at `path` to find the modules and files it directly depends on.
+// The result, errors included, is stored in getDependenciesCache.
+func getDirectDependencies(ctx *TerragruntParsingContext, path string) ([]dependencyEdge, error) {
+	// Check if this path has already been computed
+	cachedResult, ok := getDependenciesCache.get(path)
+	if ok {
+		return cachedResult.dependencies, cachedResult.err
+	}
+
+	output, err := parseDirectDependencies(ctx, path)
+	output.err = err
+	getDependenciesCache.set(path, output)
+	return output.dependencies, err
+}
+
+func parseDirectDependencies(ctx *TerragruntParsingContext, path string) (getDependenciesOutput, error) {
+	// parse the module path to find what it includes, which makes the included configs parents
+	sourceless, includes, err := parseModule(ctx, path)
+	if err != nil {
+		return getDependenciesOutput{}, err
+	}
+
+	output := getDependenciesOutput{}
+	dependencies := make([]dependencyEdge, 0, 8) // Pre-allocate with small capacity
+	for _, includeDep := range includes {
+		dependencies = append(dependencies, dependencyEdge{includeDep.Path, dependencyKindInclude})
+
+		includePath := includeDep.Path
+		if !filepath.IsAbs(includePath) {
+			includePath = makePathAbsolute(includePath, path)
 		}
+		output.includes = append(output.includes, filepath.Clean(includePath))
+	}
 
-		// Parse the HCL file
-		parseCtx := NewParsingContextWithDecodeList(ctx)
-		terragruntConfig, err := parseCtx.PartialParseConfigFile(path)
-		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
+	// Parse the HCL file
+	parseCtx := NewParsingContextWithDecodeList(ctx)
+	terragruntConfig, err := parseCtx.PartialParseConfigFile(path)
+	if err != nil {
+		return output, err
+	}
+
+	// Parse out locals
+	locals, err := parseLocals(ctx, path, nil)
+	if err != nil {
+		return output, err
+	}
+	output.parentOverride = locals.parent
+
+	// Cascading stops at configs that are parents of their own, whatever projects are created for them
+	output.cascadeLeaf = sourceless && ignoreParentTerragrunt
+	if locals.parent != nil {
+		output.cascadeLeaf = *locals.parent && ignoreParentTerragrunt
+	}
+
+	// Get deps from locals
+	for _, extraDep := range locals.ExtraAtlantisDependencies {
+		dependencies = append(dependencies, dependencyEdge{extraDep, dependencyKindExtra})
+	}
+
+	// Get deps from `dependencies` and `dependency` blocks
+	if terragruntConfig.Dependencies != nil && !ignoreDependencyBlocks {
+		for _, parsedPaths := range terragruntConfig.Dependencies.Paths {
+			dependencies = append(dependencies, dependencyEdge{filepath.Join(parsedPaths, terragruntConfigFile), dependencyKindDependency})
 		}
+	}
+
+	// Get deps from the `Source` field of the `Terraform` block
+	if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.Source != nil {
+		source := terragruntConfig.Terraform.Source
 
-		// Parse out locals
-		locals, err := parseLocals(ctx, path, nil)
+		// Use `go-getter` to normalize the source paths
+		parsedSource, err := getter.Detect(*source, filepath.Dir(path), getter.Detectors)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
+			return output, err
 		}
 
-		// Get deps from locals
-		if locals.ExtraAtlantisDependencies != nil {
-			dependencies = sliceUnion(dependencies, locals.ExtraAtlantisDependencies)
+		// Check if the path begins with a drive letter, denoting Windows
+		isWindowsPath, err := regexp.MatchString(windowsDrivePattern, parsedSource)
+		if err != nil {
+			return output, err
 		}
 
-		// Get deps from `dependencies` and `dependency` blocks
-		if terragruntConfig.Dependencies != nil && !ignoreDependencyBlocks {
-			for _, parsedPaths := range terragruntConfig.Dependencies.Paths {
-				dependencies = append(dependencies, filepath.Join(parsedPaths, terragruntConfigFile))
-			}
-		}
+		// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
+		if strings.HasPrefix(parsedSource, fileProtocolPrefix) || isWindowsPath {
+			// Remove the prefix so we have a valid filesystem path
+			parsedSource = strings.TrimPrefix(parsedSource, fileProtocolPrefix)
 
-		// Get deps from the `Source` field of the `Terraform` block
-		if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.Source != nil {
-			source := terragruntConfig.Terraform.Source
+			dependencies = append(dependencies, dependencyEdge{filepath.Join(parsedSource, terraformFilePattern), dependencyKindSource})
+			dependencies = append(dependencies, dependencyEdge{filepath.Join(parsedSource, tofuFilePattern), dependencyKindSource})
 
-			// Use `go-getter` to normalize the source paths
-			parsedSource, err := getter.Detect(*source, filepath.Dir(path), getter.Detectors)
+			ls, err := parseTerraformLocalModuleSource(parsedSource)
 			if err != nil {
-				return nil, err
+				return output, err
 			}
+			sort.Strings(ls)
 
-			// Check if the path begins with a drive letter, denoting Windows
-			isWindowsPath, err := regexp.MatchString(`^[A-Za-z]:`, parsedSource)
-			if err != nil {
-				return nil, err
-			}
-
-			// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
-			if strings.HasPrefix(parsedSource, fileProtocolPrefix) || isWindowsPath {
-				// Remove the prefix so we have a valid filesystem path
-				parsedSource = strings.TrimPrefix(parsedSource, fileProtocolPrefix)
-
-				dependencies = append(dependencies, filepath.Join(parsedSource, terraformFilePattern))
-				dependencies = append(dependencies, filepath.Join(parsedSource, tofuFilePattern))
-
-				ls, err := parseTerraformLocalModuleSource(parsedSource)
-				if err != nil {
-					return nil, err
-				}
-				sort.Strings(ls)
-
-				dependencies = append(dependencies, ls...)
+			for _, localSource := range ls {
+				dependencies = append(dependencies, dependencyEdge{localSource, dependencyKindSource})
 			}
 		}
+	}
 
-		// Get deps from `extra_arguments` fields of the `Terraform` block
-		if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.ExtraArgs != nil {
-			extraArgs := terragruntConfig.Terraform.ExtraArgs
-			for _, arg := range extraArgs {
-				if arg.RequiredVarFiles != nil {
-					dependencies = append(dependencies, *arg.RequiredVarFiles...)
+	// Get deps from `extra_arguments` fields of the `Terraform` block
+	if terragruntConfig.Terraform != nil && terragruntConfig.Terraform.ExtraArgs != nil {
+		extraArgs := terragruntConfig.Terraform.ExtraArgs
+		for _, arg := range extraArgs {
+			if arg.RequiredVarFiles != nil {
+				for _, varFile := range *arg.RequiredVarFiles {
+					dependencies = append(dependencies, dependencyEdge{varFile, dependencyKindVarFile})
 				}
-				if arg.OptionalVarFiles != nil {
-					dependencies = append(dependencies, *arg.OptionalVarFiles...)
+			}
+			if arg.OptionalVarFiles != nil {
+				for _, varFile := range *arg.OptionalVarFiles {
+					dependencies = append(dependencies, dependencyEdge{varFile, dependencyKindVarFile})
 				}
-				if arg.Arguments != nil {
-					for _, cliFlag := range *arg.Arguments {
-						if strings.HasPrefix(cliFlag, "-var-file=") {
-							dependencies = append(dependencies, strings.TrimPrefix(cliFlag, "-var-file="))
-						}
+			}
+			if arg.Arguments != nil {
+				for _, cliFlag := range *arg.Arguments {
+					if strings.HasPrefix(cliFlag, "-var-file=") {
+						dependencies = append(dependencies, dependencyEdge{strings.TrimPrefix(cliFlag, "-var-file="), dependencyKindVarFile})
 					}
 				}
 			}
 		}
+	}
 
-		// Filter out and dependencies that are the empty string
-		nonEmptyDeps := make([]string, 0, len(dependencies))
-		for _, dep := range dependencies {
-			if dep != "" {
-				childDepAbsPath := dep
-				if !filepath.IsAbs(childDepAbsPath) {
-					childDepAbsPath = makePathAbsolute(dep, path)
-				}
-				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-				nonEmptyDeps = append(nonEmptyDeps, childDepAbsPath)
+	// Filter out and dependencies that are the empty string
+	nonEmptyDeps := make([]dependencyEdge, 0, len(dependencies))
+	for _, dep := range dependencies {
+		if dep.path != "" {
+			childDepAbsPath := dep.path
+			if !filepath.IsAbs(childDepAbsPath) {
+				childDepAbsPath = makePathAbsolute(dep.path, path)
 			}
+			nonEmptyDeps = append(nonEmptyDeps, dependencyEdge{filepath.ToSlash(childDepAbsPath), dep.kind})
 		}
+	}
 
-		// Recurse to find dependencies of all dependencies
-		cascadedDeps := make([]string, 0, len(nonEmptyDeps)*2) // Estimate double capacity for cascaded deps
-		for _, dep := range nonEmptyDeps {
-			cascadedDeps = append(cascadedDeps, dep)
-
-			// The "cascading" feature is protected by a flag
-			if !cascadeDependencies {
-				continue
-			}
+	// Modules called from terraform code in the same directory
+	if filepath.Base(path) == terragruntConfigFile {
+		dir := filepath.Dir(path)
 
-			depPath := dep
-			terrContext := ctx.WithDependencyPath(depPath)
-			childDeps, err := getDependencies(terrContext, depPath)
-			if err != nil {
-				continue
-			}
+		ls, err := parseTerraformLocalModuleSource(dir)
+		if err != nil {
+			return output, err
+		}
+		sort.Strings(ls)
 
-			for _, childDep := range childDeps {
-				// If `childDep` is a relative path, it will be relative to `childDep`, as it is from the nested
-				// `getDependencies` call on the top level module's dependencies. So here we update any relative
-				// path to be from the top level module instead.
-				childDepAbsPath := childDep
-				if !filepath.IsAbs(childDep) {
-					childDepAbsPath, err = filepath.Abs(filepath.Join(depPath, "..", childDep))
-					if err != nil {
-						getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-						return nil, err
-					}
-				}
-				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-
-				// Ensure we are not adding a duplicate dependency
-				alreadyExists := false
-				for _, dep := range cascadedDeps {
-					if dep == childDepAbsPath {
-						alreadyExists = true
-						break
-					}
-				}
-				if !alreadyExists {
-					cascadedDeps = append(cascadedDeps, childDepAbsPath)
-				}
-			}
+		for _, localSource := range ls {
+			nonEmptyDeps = append(nonEmptyDeps, dependencyEdge{localSource, dependencyKindLocalModule})
 		}
+	}
 
-		if filepath.Base(path) == terragruntConfigFile {
-			dir := filepath.Dir(path)
+	nonEmptyDeps, err = checkOutsideDependencies(path, nonEmptyDeps)
+	if err != nil {
+		return output, err
+	}
 
-			ls, err := parseTerraformLocalModuleSource(dir)
-			if err != nil {
-				return nil, err
-			}
-			sort.Strings(ls)
+	output.dependencies = nonEmptyDeps
+	output.cascadeDepth = locals.CascadeDepth
+	return output, nil
+}
 
-			cascadedDeps = append(cascadedDeps, ls...)
+// Values of the `--on-parse-error` flag
+const (
+	onParseErrorFail         = "fail"
+	onParseErrorSkip         = "skip"
+	onParseErrorConservative = "conservative"
+)
+
+// Creates the AtlantisProjects for a directory. Modules that fail to parse are handled according to `--on-parse-error`.
+func createProject(ctx context.Context, sourcePath string) ([]AtlantisProject, error) {
+	projects, err := createProjectFromConfig(ctx, sourcePath)
+	if err == nil || ctx.Err() != nil {
+		return projects, err
+	}
+
+	// `--on-outside-dependency=error` fails the run whatever `--on-parse-error` is
+	var outsideErr outsideDependencyError
+	if errors.As(err, &outsideErr) {
+		return nil, err
+	}
+
+	switch onParseError {
+	case onParseErrorSkip:
+		log.Warnf("Skipping %s, as it failed to parse: %s", sourcePath, firstLine(err))
+		return nil, nil
+	case onParseErrorConservative:
+		log.Warnf("Creating a conservative project for %s, as it failed to parse: %s", sourcePath, firstLine(err))
+		project, err := createConservativeProject(ctx, sourcePath, err)
+		if err != nil {
+			return nil, err
 		}
+		return []AtlantisProject{*project}, nil
+	}
 
-		getDependenciesCache.set(path, getDependenciesOutput{cascadedDeps, err})
-		return cascadedDeps, nil
-	})
+	return nil, err
+}
 
-	if res != nil {
-		return res.([]string), err
-	} else {
+// Creates an AtlantisProject for a module that could not be parsed. As neither its locals nor its dependencies
+// are known, the project uses the flags and rules, and is planned on any change to its own directory and the ones
+// below it, to the hcl files of all directories above it, and to the `--conservative-when-modified` patterns.
+func createConservativeProject(ctx context.Context, sourcePath string, parseErr error) (*AtlantisProject, error) {
+	absoluteSourceDir := filepath.Dir(sourcePath)
+	relativeSourceDir, err := filepath.Rel(strings.TrimSuffix(gitRoot, string(filepath.Separator)), absoluteSourceDir)
+	if err != nil {
 		return nil, err
 	}
+	relativeSourceDir = filepath.ToSlash(relativeSourceDir)
+
+	// The module can use any file below its directory, such as templates
+	whenModified := []string{"*", "**/*"}
+
+	// Every directory up to the root can hold parent configs, which are not known without parsing
+	pathToRoot := ""
+	if relativeSourceDir != "." {
+		for range strings.Split(relativeSourceDir, "/") {
+			pathToRoot += "../"
+			whenModified = append(whenModified, pathToRoot+"*.hcl")
+		}
+	}
+
+	for _, pattern := range conservativeWhenModified {
+		whenModified = append(whenModified, pathToRoot+strings.TrimPrefix(filepath.ToSlash(pattern), "/"))
+	}
+
+	// Rules still apply, but only those without conditions on locals can match
+	locals, ruleDependencies := applyProjectRules(relativeSourceDir, ResolvedLocals{})
+	for _, dependency := range ruleDependencies {
+		relativePath, err := filepath.Rel(absoluteSourceDir, dependency)
+		if err != nil {
+			return nil, err
+		}
+		whenModified = append(whenModified, filepath.ToSlash(relativePath))
+	}
+
+	project := newProject(relativeSourceDir, uniqueStrings(whenModified), locals, matrixEnvFromContext(ctx))
+	project.warnings = []string{"failed to parse, using a conservative when_modified: " + firstLine(parseErr)}
+
+	// Without the locals, templates using them can't be rendered, so those fall back to the directory
+	if err := nameProject(project, locals, ""); err != nil {
+		log.Warnf("Naming the conservative project for %s after its directory: %s", sourcePath, err)
+		project.warnings = append(project.warnings, "could not render the name templates")
+		nameProjectAfterDir(project, "")
+	}
+
+	return project, nil
 }
 
-// Creates an AtlantisProject for a directory
-func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, error) {
+// Creates the AtlantisProjects for a directory from its parsed terragrunt config
+func createProjectFromConfig(ctx context.Context, sourcePath string) ([]AtlantisProject, error) {
+	// Parents are only projects of their own with `--create-parent-project`, or like any other module when not
+	// ignored
+	parent := isParentConfig(sourcePath)
+	if parent && ignoreParentTerragrunt && !createParentProject {
+		return nil, nil
+	}
+	parentProject := parent && createParentProject
+
 	parsingContext, err := NewParsingContextWithConfigPath(ctx, sourcePath)
 	if err != nil {
 		return nil, err
@@ -385,6 +485,18 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		return nil, err
 	}
 
+	// Clean up the relative path to the format Atlantis expects
+	relativeSourceDir := strings.TrimPrefix(absoluteSourceDir, gitRoot)
+	relativeSourceDir = strings.TrimSuffix(relativeSourceDir, string(filepath.Separator))
+	if relativeSourceDir == "" {
+		relativeSourceDir = "."
+	}
+
+	// Settings from the rules of the config file sit between the flags and the locals
+	locals, ruleDependencies := applyProjectRules(filepath.ToSlash(relativeSourceDir), locals)
+	// Copied, as the memoized closure of the dependency graph is shared with other projects
+	dependencies = append(append([]string{}, dependencies...), ruleDependencies...)
+
 	// If `atlantis_skip` is true on the module, then do not produce a project for it
 	if locals.Skip != nil && *locals.Skip {
 		return nil, nil
@@ -409,74 +521,32 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
 	}
 
-	// Clean up the relative path to the format Atlantis expects
-	relativeSourceDir := strings.TrimPrefix(absoluteSourceDir, gitRoot)
-	relativeSourceDir = strings.TrimSuffix(relativeSourceDir, string(filepath.Separator))
-	if relativeSourceDir == "" {
-		relativeSourceDir = "."
+	// Changes to any of the children below a parent can plan it as well
+	if parentProject && parentProjectChildren {
+		relativeDependencies = append(relativeDependencies, "**/*.hcl", "**/"+terraformFilePattern, "**/"+tofuFilePattern)
 	}
 
-	workflow := defaultWorkflow
-	if locals.AtlantisWorkflow != "" {
-		workflow = locals.AtlantisWorkflow
-	}
-
-	applyRequirements := &defaultApplyRequirements
-	if len(defaultApplyRequirements) == 0 {
-		applyRequirements = nil
-	}
-	if locals.ApplyRequirements != nil {
-		applyRequirements = &locals.ApplyRequirements
-	}
-
-	resolvedAutoPlan := autoPlan
-	if locals.AutoPlan != nil {
-		resolvedAutoPlan = *locals.AutoPlan
-	}
-
-	terraformVersion := defaultTerraformVersion
-	if locals.TerraformVersion != "" {
-		terraformVersion = locals.TerraformVersion
-	}
-
-	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(relativeSourceDir),
-		Workflow:          workflow,
-		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
-		Autoplan: AutoplanConfig{
-			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(relativeDependencies),
-		},
-	}
-
-	// Terraform Cloud limits the workspace names to be less than 90 characters
-	// with letters, numbers, -, and _
-	// https://www.terraform.io/docs/cloud/workspaces/naming.html
-	// It is not clear from documentation whether the normal workspaces have those limitations
-	// However a workspace 97 chars long has been working perfectly.
-	// We are going to use the same name for both workspace & project name as it is unique.
-	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
-	projectName := regex.ReplaceAllString(project.Dir, "_")
-
-	if createProjectName {
-		project.Name = projectName
+	projects, err := newProjects(filepath.ToSlash(relativeSourceDir), uniqueStrings(relativeDependencies), locals, matrixEnvFromContext(ctx))
+	if err != nil {
+		return nil, err
 	}
 
-	if createWorkspace {
-		project.Workspace = projectName
+	// The locals of a parent are inherited by its children, so its own workflow comes from a flag instead
+	if parentProject && parentWorkflow != "" {
+		for i := range projects {
+			projects[i].Workflow = parentWorkflow
+		}
 	}
 
-	return project, nil
+	return projects, nil
 }
 
-func createHclProject(ctx context.Context, sourcePaths []string, workingDir string, projectHcl string) (*AtlantisProject, error) {
+// Creates the project of the project hcl file `projectHcl` in `workingDir`, for the modules of `sourcePaths`. The
+// subtrees of the project hcl dirs `innerDirs` below it are excluded, except for the dependencies in them.
+func createHclProject(ctx context.Context, sourcePaths []string, workingDir string, projectHcl string, innerDirs []string, innerModules map[string]bool) ([]AtlantisProject, error) {
 	var projectHclDependencies []string
 	var childDependencies []string
-	workflow := defaultWorkflow
-	applyRequirements := &defaultApplyRequirements
-	resolvedAutoPlan := autoPlan
-	terraformVersion := defaultTerraformVersion
+	var innerDependencies []string
 
 	projectHclFile := filepath.Join(workingDir, projectHcl)
 	parsingContext, err := NewParsingContextWithConfigPath(ctx, workingDir)
@@ -488,6 +558,20 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 	if err != nil {
 		return nil, err
 	}
+	dir, err := filepath.Rel(gitRoot, workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Settings from the rules of the config file sit between the flags and the locals
+	locals, ruleDependencies := applyProjectRules(filepath.ToSlash(dir), locals)
+	for _, dep := range ruleDependencies {
+		relDep, err := filepath.Rel(workingDir, dep)
+		if err != nil {
+			return nil, err
+		}
+		projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(relDep))
+	}
 
 	// If `atlantis_skip` is true on the module, then do not produce a project for it
 	if locals.Skip != nil && *locals.Skip {
@@ -513,27 +597,13 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 		}
 	}
 
-	if locals.AtlantisWorkflow != "" {
-		workflow = locals.AtlantisWorkflow
-	}
-
-	if len(defaultApplyRequirements) == 0 {
-		applyRequirements = nil
-	}
-	if locals.ApplyRequirements != nil {
-		applyRequirements = &locals.ApplyRequirements
-	}
-
-	if locals.AutoPlan != nil {
-		resolvedAutoPlan = *locals.AutoPlan
-	}
-
-	if locals.TerraformVersion != "" {
-		terraformVersion = locals.TerraformVersion
-	}
-
 	// build dependencies for terragrunt childs in directories below project hcl file
 	for _, sourcePath := range sourcePaths {
+		// Parents are covered by the children that include them
+		if isParentConfig(sourcePath) && ignoreParentTerragrunt {
+			continue
+		}
+
 		parsingContext, err := NewParsingContextWithConfigPath(ctx, sourcePath)
 		if err != nil {
 			return nil, err
@@ -556,6 +626,12 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 			"**/" + terraformFilePattern,
 			"**/" + tofuFilePattern,
 		}
+		if preciseHclProjectWhenModified {
+			relativeDependencies, err = moduleFilePatterns(workingDir, sourcePath)
+			if err != nil {
+				return nil, err
+			}
+		}
 		for _, dependencyPath := range dependencies {
 			absolutePath := dependencyPath
 			if !filepath.IsAbs(absolutePath) {
@@ -567,69 +643,141 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 				return nil, err
 			}
 
-			if strings.HasPrefix(relativePath, "..") {
+			// Changes to the modules of nested projects only plan those projects. Their other files, like
+			// shared includes, are not covered by them and still plan this one.
+			if innerModules[filepath.Clean(absolutePath)] {
+				continue
+			}
+
+			if strings.HasPrefix(relativePath, "..") || preciseHclProjectWhenModified {
 				relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
+			} else if withinAny(absolutePath, innerDirs) {
+				innerDependencies = append(innerDependencies, filepath.ToSlash(relativePath))
 			}
 		}
 
 		childDependencies = append(childDependencies, relativeDependencies...)
 	}
-	dir, err := filepath.Rel(gitRoot, workingDir)
+
+	if preciseHclProjectWhenModified {
+		// Only the project hcl file and the files of its modules, which never reach into nested project hcl dirs
+		whenModified := append([]string{projectHcl}, childDependencies...)
+		whenModified = uniqueStrings(append(whenModified, projectHclDependencies...))
+		if compressHclProjectWhenModified {
+			whenModified = compressWhenModified(workingDir, whenModified)
+		}
+		return newProjects(filepath.ToSlash(dir), dropCoveredPaths(whenModified), locals, matrixEnvFromContext(ctx))
+	}
+
+	// Changes below the project hcl files nested in this one only plan their own projects
+	exclusions, err := excludedSubtrees(workingDir, innerDirs)
 	if err != nil {
 		return nil, err
 	}
+	whenModified := append(childDependencies, exclusions...)
+	whenModified = append(whenModified, innerDependencies...)
+	whenModified = append(whenModified, projectHclDependencies...)
 
-	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(dir),
-		Workflow:          workflow,
-		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
-		Autoplan: AutoplanConfig{
-			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(append(childDependencies, projectHclDependencies...)),
-		},
+	return newProjects(filepath.ToSlash(dir), uniqueStrings(whenModified), locals, matrixEnvFromContext(ctx))
+}
+
+// Creates the AtlantisProjects of a directory: a single one, or one per workspace of `atlantis_workspaces`.
+// All of them share `whenModified`. `env` is the environment of `--env-matrix` they are generated for, if any.
+func newProjects(dir string, whenModified []string, locals ResolvedLocals, env *matrixEnv) ([]AtlantisProject, error) {
+	if locals.Workspaces == nil {
+		project := newProject(dir, whenModified, locals, env)
+		if err := nameProject(project, locals, ""); err != nil {
+			return nil, err
+		}
+		return []AtlantisProject{*project}, nil
 	}
 
-	// Terraform Cloud limits the workspace names to be less than 90 characters
-	// with letters, numbers, -, and _
-	// https://www.terraform.io/docs/cloud/workspaces/naming.html
-	// It is not clear from documentation whether the normal workspaces have those limitations
-	// However a workspace 97 chars long has been working perfectly.
-	// We are going to use the same name for both workspace & project name as it is unique.
-	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
-	projectName := regex.ReplaceAllString(project.Dir, "_")
+	projects := make([]AtlantisProject, 0, len(locals.Workspaces))
+	for _, workspace := range locals.Workspaces {
+		workspaceLocals := mergeResolvedLocals(locals, workspace.overrides)
 
-	if createProjectName {
-		project.Name = projectName
+		// A name given to the whole module is shared by all of its workspaces
+		if workspace.overrides.ProjectName == "" && locals.ProjectName != "" {
+			workspaceLocals.ProjectName = locals.ProjectName + "_" + workspace.name
+		}
+
+		project := newProject(dir, whenModified, workspaceLocals, env)
+		if err := nameProject(project, workspaceLocals, workspace.name); err != nil {
+			return nil, err
+		}
+		projects = append(projects, *project)
 	}
+	return projects, nil
+}
 
-	if createWorkspace {
-		project.Workspace = projectName
+// Creates an unnamed AtlantisProject, with the settings of the flags overridden by the environment of
+// `--env-matrix`, if any, and then by the locals
+func newProject(dir string, whenModified []string, locals ResolvedLocals, env *matrixEnv) *AtlantisProject {
+	workflow := defaultWorkflow
+	envName := ""
+	if env != nil {
+		envName = env.Name
+		if env.Workflow != "" {
+			workflow = env.Workflow
+		}
+	}
+	if locals.AtlantisWorkflow != "" {
+		workflow = locals.AtlantisWorkflow
 	}
 
-	return project, nil
+	applyRequirements := &defaultApplyRequirements
+	if len(defaultApplyRequirements) == 0 {
+		applyRequirements = nil
+	}
+	if locals.ApplyRequirements != nil {
+		applyRequirements = &locals.ApplyRequirements
+	}
+
+	resolvedAutoPlan := autoPlan
+	if locals.AutoPlan != nil {
+		resolvedAutoPlan = *locals.AutoPlan
+	}
+
+	terraformVersion := defaultTerraformVersion
+	if locals.TerraformVersion != "" {
+		terraformVersion = locals.TerraformVersion
+	}
+
+	return &AtlantisProject{
+		Dir:               dir,
+		Workflow:          workflow,
+		TerraformVersion:  terraformVersion,
+		ApplyRequirements: applyRequirements,
+		Autoplan: AutoplanConfig{
+			Enabled:      resolvedAutoPlan,
+			WhenModified: whenModified,
+		},
+		env: envName,
+	}
 }
 
-// Finds the absolute paths of all arbitrary project hcl files
+// Finds the absolute paths of all arbitrary project hcl files in the scan roots
 func getAllTerragruntProjectHclFiles() map[string][]string {
 	projectHclFiles := projectHclFiles
 	orderedHclFilePaths := map[string][]string{}
 	uniqueHclFileAbsPaths := map[string][]string{}
 	for _, projectHclFile := range projectHclFiles {
-		err := filepath.Walk(gitRoot, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+		for _, scanRoot := range scanRoots {
+			err := walkDir(scanRoot, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
 
-			if !info.IsDir() && info.Name() == projectHclFile {
-				orderedHclFilePaths[projectHclFile] = append(orderedHclFilePaths[projectHclFile], filepath.Dir(path))
-			}
+				if !info.IsDir() && info.Name() == projectHclFile {
+					orderedHclFilePaths[projectHclFile] = append(orderedHclFilePaths[projectHclFile], filepath.Dir(path))
+				}
 
-			return nil
-		})
+				return nil
+			})
 
-		if err != nil {
-			log.Fatal(err)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
 
 		for _, uniquePath := range orderedHclFilePaths[projectHclFile] {
@@ -644,26 +792,82 @@ func getAllTerragruntProjectHclFiles() map[string][]string {
 }
 
 func main(cmd *cobra.Command, args []string) error {
-	// Ensure the gitRoot has a trailing slash and is an absolute path
-	absoluteGitRoot, err := filepath.Abs(gitRoot)
+	if debug {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+	if err := validateCascadeEdgeKinds(cascadeEdgeKinds); err != nil {
+		return err
+	}
+	for _, requirement := range defaultApplyRequirements {
+		if !isValidApplyRequirement(requirement) {
+			return fmt.Errorf("unknown requirement %q in --apply-requirements, must be one of: %s", requirement, strings.Join(validApplyRequirements, ", "))
+		}
+	}
+	switch onParseError {
+	case onParseErrorFail, onParseErrorSkip, onParseErrorConservative:
+	default:
+		return fmt.Errorf("unknown value %q for --on-parse-error, must be one of: %s, %s, %s", onParseError, onParseErrorFail, onParseErrorSkip, onParseErrorConservative)
+	}
+	switch onOutsideDependency {
+	case onOutsideDependencyWarn, onOutsideDependencyError, onOutsideDependencyDrop:
+	default:
+		return fmt.Errorf("unknown value %q for --on-outside-dependency, must be one of: %s, %s, %s", onOutsideDependency, onOutsideDependencyWarn, onOutsideDependencyError, onOutsideDependencyDrop)
+	}
+	outsideDeps = newOutsideDependencies()
+	if err := parseNameTemplates(); err != nil {
+		return err
+	}
+	// Every module is evaluated with the same environment variables, read once
+	env, err := buildEvalEnv()
 	if err != nil {
 		return err
 	}
-	gitRoot = absoluteGitRoot + string(filepath.Separator)
-	workingDirs := []string{gitRoot}
+	evalEnv = env
+	missingEnv = newMissingEnvVars()
+
+	// Stubs replace the functions that run commands or call cloud APIs
+	sandboxStubs = sandboxStubFile{}
+	sandboxCalls = newStubbedCalls()
+	if sandboxStubsPath != "" {
+		sandbox = true
+		sandboxStubs, err = readSandboxStubs(sandboxStubsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Ensure the gitRoot and the scan roots have a trailing slash and are absolute paths
+	if err := resolveRoots(); err != nil {
+		return err
+	}
+	workingDirs := append([]string{}, scanRoots...)
 	projectHclDirMap := map[string][]string{}
 	var projectHclDirs []string
 	if len(projectHclFiles) > 0 {
 		workingDirs = nil
 		// map [project-hcl-file] => directories containing project-hcl-file
 		projectHclDirMap = getAllTerragruntProjectHclFiles()
+		if projectHclPrecedence {
+			projectHclDirMap = applyProjectHclPrecedence(projectHclDirMap)
+		}
 		for _, projectHclFile := range projectHclFiles {
 			projectHclDirs = append(projectHclDirs, projectHclDirMap[projectHclFile]...)
-			workingDirs = append(workingDirs, projectHclDirMap[projectHclFile]...)
+			for _, projectHclDir := range projectHclDirMap[projectHclFile] {
+				// With `--filter`, only the projects of the project hcl files it selects are generated
+				selected, err := isSelectedByFilters(projectHclDir)
+				if err != nil {
+					return err
+				}
+				if selected {
+					workingDirs = append(workingDirs, projectHclDir)
+				}
+			}
 		}
 		// parse terragrunt child modules outside the scope of projectHclDirs
 		if createHclProjectExternalChilds {
-			workingDirs = append(workingDirs, gitRoot)
+			workingDirs = append(workingDirs, scanRoots...)
 		}
 	}
 	// Read in the old config, if it already exists
@@ -682,6 +886,9 @@ func main(cmd *cobra.Command, args []string) error {
 	}
 	if oldConfig != nil && preserveProjects {
 		config.Projects = oldConfig.Projects
+		for i := range config.Projects {
+			config.Projects[i].preserved = true
+		}
 	}
 
 	lock := sync.Mutex{}
@@ -695,153 +902,210 @@ func main(cmd *cobra.Command, args []string) error {
 	errGroup, _ := errgroup.WithContext(ctx)
 	sem := semaphore.NewWeighted(numExecutors)
 
-	for _, workingDir := range workingDirs {
-		// Check if context was cancelled (e.g., by SIGTERM/SIGINT)
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+	// With `--keep-going`, errors of single modules are collected here instead of aborting the run
+	failures := &moduleFailures{}
 
-		terragruntFiles, err := getAllTerragruntFiles(workingDir)
+	// With `--env-matrix`, all modules are evaluated once per environment, with separate dependency graphs
+	envs := []*matrixEnv{nil}
+	if envMatrixPath != "" {
+		matrix, err := readEnvMatrix(envMatrixPath)
 		if err != nil {
 			return err
 		}
+		envs = envs[:0]
+		for i := range matrix {
+			envs = append(envs, &matrix[i])
+		}
+	}
+
+	baseCtx := ctx
+	for _, env := range envs {
+		ctx := withMatrixEnv(baseCtx, env)
+		if env != nil {
+			log.Info("Generating projects for environment ", env.Name)
+			getDependenciesCache = newGetDependenciesCache()
+			moduleGraph = newDependencyGraph()
+		}
 
-		if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && workingDir == gitRoot) {
-			// Concurrently looking all dependencies
-			for _, terragruntPath := range terragruntFiles {
-				// Check if context was cancelled
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				default:
+		// Build the direct dependency edges of all modules up front, so that every project only needs a lookup of
+		// its transitive closure, and parents are known from the includes of all modules. Modules that `--filter`
+		// leaves out still include their parents, so all configs in the scan roots are parsed.
+		terragruntFilesByDir := make(map[string][]string, len(workingDirs))
+		projectHclFilesByDir := make(map[string][]string, len(projectHclDirs))
+		discovered := []string{}
+		for _, scanRoot := range scanRoots {
+			allTerragruntFiles, err := findTerragruntFiles(scanRoot, []string{scanRoot})
+			if err != nil {
+				return err
+			}
+			discovered = append(discovered, allTerragruntFiles...)
+		}
+		for _, workingDir := range workingDirs {
+			terragruntFiles, err := getAllTerragruntFiles(workingDir)
+			if err != nil {
+				return err
+			}
+			terragruntFilesByDir[workingDir] = terragruntFiles
+			discovered = append(discovered, terragruntFiles...)
+
+			// The project of a project hcl file covers all modules below it, not just those selected by `--filter`
+			if len(projectHclDirs) > 0 && !isScanRoot(workingDir) {
+				allTerragruntFiles, err := findTerragruntFiles(workingDir, []string{workingDir})
+				if err != nil {
+					return err
 				}
+				projectHclFilesByDir[workingDir] = allTerragruntFiles
+				discovered = append(discovered, allTerragruntFiles...)
+			}
+		}
+		discovered = uniqueStrings(discovered)
+		if err := moduleGraph.expand(ctx, discovered); err != nil {
+			return err
+		}
+		parentConfigs = classifyParents(discovered)
+
+		for _, workingDir := range workingDirs {
+			// Check if context was cancelled (e.g., by SIGTERM/SIGINT)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
 
-				terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
+			terragruntFiles := terragruntFilesByDir[workingDir]
 
-				// don't create atlantis projects already covered by project hcl file projects
-				skipProject := false
-				if createHclProjectExternalChilds && workingDir == gitRoot && len(projectHclDirs) > 0 {
-					for _, projectHclDir := range projectHclDirs {
-						if strings.HasPrefix(terragruntPath, projectHclDir) {
-							skipProject = true
-							break
+			if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && isScanRoot(workingDir)) {
+				// Concurrently looking all dependencies
+				for _, terragruntPath := range terragruntFiles {
+					// Check if context was cancelled
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					default:
+					}
+
+					terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
+
+					// don't create atlantis projects already covered by project hcl file projects
+					skipProject := false
+					if createHclProjectExternalChilds && isScanRoot(workingDir) && len(projectHclDirs) > 0 {
+						for _, projectHclDir := range projectHclDirs {
+							if strings.HasPrefix(terragruntPath, projectHclDir) {
+								skipProject = true
+								break
+							}
 						}
 					}
+					if skipProject {
+						continue
+					}
+					if err := sem.Acquire(ctx, 1); err != nil {
+						return err
+					}
+
+					errGroup.Go(func() error {
+						defer sem.Release(1)
+						projects, err := createProject(ctx, terragruntPath)
+						if err != nil {
+							err = wrapEnvError(ctx, err)
+							if keepGoing && ctx.Err() == nil {
+								log.Error("Failed to create project for ", terragruntPath)
+								failures.add(terragruntPath, err)
+								return nil
+							}
+							return err
+						}
+						// if there are no projects then skip this module
+						if len(projects) == 0 {
+							return nil
+						}
+
+						// Lock the list as only one goroutine should be writing to config.Projects at a time
+						lock.Lock()
+						defer lock.Unlock()
+
+						addProjects(&config, projects, "project", terragruntPath)
+
+						return nil
+					})
 				}
-				if skipProject {
-					continue
+
+				if err := errGroup.Wait(); err != nil {
+					// If context was cancelled, prioritize that error for cleaner shutdown message
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					return err
 				}
-				if err := sem.Acquire(ctx, 1); err != nil {
+			}
+			if len(projectHclDirs) > 0 && !isScanRoot(workingDir) {
+				projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
+				err := sem.Acquire(ctx, 1)
+				if err != nil {
 					return err
 				}
 
 				errGroup.Go(func() error {
 					defer sem.Release(1)
-					project, err := createProject(ctx, terragruntPath)
+					// Every module belongs to the project of the innermost project hcl file above it
+					sourcePaths := ownedModules(workingDir, projectHclFilesByDir[workingDir], projectHclDirs)
+					innerDirs := innerProjectHclDirs(workingDir, projectHclDirs)
+					innerModules := innerModuleConfigs(projectHclFilesByDir[workingDir], innerDirs)
+					projects, err := createHclProject(ctx, sourcePaths, workingDir, projectHcl, innerDirs, innerModules)
 					if err != nil {
+						err = wrapEnvError(ctx, err)
+						if keepGoing && ctx.Err() == nil {
+							log.Error("Failed to create "+projectHcl+" project for ", workingDir)
+							failures.add(filepath.Join(workingDir, projectHcl), err)
+							return nil
+						}
 						return err
 					}
-					// if project is nil then skip this project
-					if project == nil {
+					// if there are no projects then skip this project hcl file
+					if len(projects) == 0 {
 						return nil
 					}
-
 					// Lock the list as only one goroutine should be writing to config.Projects at a time
 					lock.Lock()
 					defer lock.Unlock()
 
-					// When preserving existing projects, we should update existing blocks instead of creating a
-					// duplicate, when generating something which already has representation
-					if preserveProjects {
-						updateProject := false
-
-						// TODO: with Go 1.19, we can replace for loop with slices.IndexFunc for increased performance
-						for i := range config.Projects {
-							if config.Projects[i].Dir == project.Dir {
-								updateProject = true
-								log.Info("Updated project for ", terragruntPath)
-								config.Projects[i] = *project
-
-								// projects should be unique, let's exit for loop for performance
-								// once first occurrence is found and replaced
-								break
-							}
-						}
-
-						if !updateProject {
-							log.Info("Created project for ", terragruntPath)
-							config.Projects = append(config.Projects, *project)
-						}
-					} else {
-						log.Info("Created project for ", terragruntPath)
-						config.Projects = append(config.Projects, *project)
-					}
+					addProjects(&config, projects, projectHcl+" project", workingDir)
 
 					return nil
 				})
-			}
 
-			if err := errGroup.Wait(); err != nil {
-				// If context was cancelled, prioritize that error for cleaner shutdown message
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-				return err
-			}
-		}
-		if len(projectHclDirs) > 0 && workingDir != gitRoot {
-			projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
-			err := sem.Acquire(ctx, 1)
-			if err != nil {
-				return err
-			}
-
-			errGroup.Go(func() error {
-				defer sem.Release(1)
-				project, err := createHclProject(ctx, terragruntFiles, workingDir, projectHcl)
-				if err != nil {
+				if err := errGroup.Wait(); err != nil {
+					// If context was cancelled, prioritize that error for cleaner shutdown message
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
 					return err
 				}
-				// if project is nil then skip this project
-				if project == nil {
-					return nil
-				}
-				// Lock the list as only one goroutine should be writing to config.Projects at a time
-				lock.Lock()
-				defer lock.Unlock()
-
-				log.Info("Created "+projectHcl+" project for ", workingDir)
-				config.Projects = append(config.Projects, *project)
-
-				return nil
-			})
-
-			if err := errGroup.Wait(); err != nil {
-				// If context was cancelled, prioritize that error for cleaner shutdown message
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-				return err
 			}
 		}
 	}
 
-	// Sort the projects in config by Dir
-	sort.Slice(config.Projects, func(i, j int) bool { return config.Projects[i].Dir < config.Projects[j].Dir })
+	// Sort the projects in config by Dir. Projects of the same directory keep the order of their workspaces.
+	sort.SliceStable(config.Projects, func(i, j int) bool { return config.Projects[i].Dir < config.Projects[j].Dir })
+
+	// Atlantis rejects configs with duplicate names, which templates and truncation make likely
+	if err := checkUniqueNames(config.Projects); err != nil {
+		return err
+	}
 
 	if executionOrderGroups || dependsOn {
-		projectsMap := make(map[string]*AtlantisProject, len(config.Projects))
+		// A directory can have several projects, one per workspace
+		projectsMap := make(map[string][]*AtlantisProject, len(config.Projects))
 		for i := range config.Projects {
-			projectsMap[config.Projects[i].Dir] = &config.Projects[i]
+			projectsMap[config.Projects[i].Dir] = append(projectsMap[config.Projects[i].Dir], &config.Projects[i])
 		}
 
 		// Compute order groups in the cycle to avoid incorrect values in cascade dependencies
 		hasChanges := true
 		for i := 0; hasChanges && i <= len(config.Projects); i++ {
 			hasChanges = false
-			for _, project := range config.Projects {
+			for j := range config.Projects {
+				project := &config.Projects[j]
 				executionOrderGroup := 0
 				dependsOnList := []string{}
 				// choose order group based on dependencies
@@ -852,24 +1116,34 @@ func main(cmd *cobra.Command, args []string) error {
 						continue
 					}
 
-					depProject, ok := projectsMap[depPath]
+					depProjects, ok := projectsMap[depPath]
 					if !ok {
 						// skip not project dependencies
 						continue
 					}
-					if depProject.ExecutionOrderGroup != nil {
-						if *depProject.ExecutionOrderGroup+1 > executionOrderGroup {
-							executionOrderGroup = *depProject.ExecutionOrderGroup + 1
+					for _, depProject := range depProjects {
+						if project.env != "" && depProject.env != "" && project.env != depProject.env {
+							// projects only depend on those of the same environment of --env-matrix
+							continue
+						}
+						if depProject.ExecutionOrderGroup != nil {
+							if *depProject.ExecutionOrderGroup+1 > executionOrderGroup {
+								executionOrderGroup = *depProject.ExecutionOrderGroup + 1
+							}
+						}
+						// Names come from the projects themselves, so renamed projects are referenced by their new
+						// name, and projects without one can't be referenced
+						if depProject.Name != "" {
+							dependsOnList = append(dependsOnList, depProject.Name)
 						}
 					}
-					dependsOnList = append(dependsOnList, depProject.Name)
 				}
-				if projectsMap[project.Dir].ExecutionOrderGroup == nil || *projectsMap[project.Dir].ExecutionOrderGroup != executionOrderGroup {
+				if project.ExecutionOrderGroup == nil || *project.ExecutionOrderGroup != executionOrderGroup {
 					if executionOrderGroups {
-						projectsMap[project.Dir].ExecutionOrderGroup = &executionOrderGroup
+						project.ExecutionOrderGroup = &executionOrderGroup
 					}
 					if dependsOn {
-						projectsMap[project.Dir].DependsOn = dependsOnList
+						project.DependsOn = dependsOnList
 					}
 					// repeat the main cycle when changed some project
 					hasChanges = true
@@ -884,7 +1158,7 @@ func main(cmd *cobra.Command, args []string) error {
 
 		// Sort by execution_order_group
 		if executionOrderGroups {
-			sort.Slice(config.Projects, func(i, j int) bool {
+			sort.SliceStable(config.Projects, func(i, j int) bool {
 				if *config.Projects[i].ExecutionOrderGroup == *config.Projects[j].ExecutionOrderGroup {
 					return config.Projects[i].Dir < config.Projects[j].Dir
 				}
@@ -901,7 +1175,10 @@ func main(cmd *cobra.Command, args []string) error {
 
 	// Ensure newline characters are correct on windows machines, as the json encoding function in the stdlib
 	// uses "\n" for all newlines regardless of OS: https://github.com/golang/go/blob/master/src/encoding/json/stream.go#L211-L217
-	yamlString := string(yamlBytes)
+	yamlString, err := annotateWarnings(string(yamlBytes), config.Projects)
+	if err != nil {
+		return err
+	}
 	if strings.Contains(runtime.GOOS, "windows") {
 		yamlString = strings.ReplaceAll(yamlString, "\n", "\r\n")
 	}
@@ -913,14 +1190,63 @@ func main(cmd *cobra.Command, args []string) error {
 		log.Println(yamlString)
 	}
 
+	reportMissingEnv()
+	reportSandboxCalls()
+	reportOutsideDependencies()
+
+	if failed := failures.sorted(); len(failed) > 0 {
+		writeFailureSummary(os.Stderr, gitRoot, failed)
+		return fmt.Errorf("failed to create projects for %d module(s)", len(failed))
+	}
+
 	return nil
 }
 
+// Adds the projects of one module to the config. When preserving projects, those of an older run with the same
+// directory and workspace are updated in place, and the other ones of that directory are dropped, as the
+// module no longer produces them. `kind` names the projects in the logs.
+func addProjects(config *AtlantisConfig, projects []AtlantisProject, kind string, sourcePath string) {
+	if !preserveProjects {
+		for _, project := range projects {
+			log.Info("Created "+kind+" for ", sourcePath)
+			config.Projects = append(config.Projects, project)
+		}
+		return
+	}
+
+	for _, project := range projects {
+		updateProject := false
+		for i := range config.Projects {
+			if config.Projects[i].Dir == project.Dir && config.Projects[i].Workspace == project.Workspace {
+				updateProject = true
+				log.Info("Updated "+kind+" for ", sourcePath)
+				config.Projects[i] = project
+				break
+			}
+		}
+
+		if !updateProject {
+			log.Info("Created "+kind+" for ", sourcePath)
+			config.Projects = append(config.Projects, project)
+		}
+	}
+
+	kept := config.Projects[:0]
+	for _, project := range config.Projects {
+		if !project.preserved || project.Dir != projects[0].Dir {
+			kept = append(kept, project)
+		}
+	}
+	config.Projects = kept
+}
+
 var gitRoot string
 var autoPlan bool
 var autoMerge bool
 var ignoreParentTerragrunt bool
 var createParentProject bool
+var parentProjectChildren bool
+var parentWorkflow string
 var ignoreDependencyBlocks bool
 var parallel bool
 var createWorkspace bool
@@ -932,6 +1258,8 @@ var outputPath string
 var preserveWorkflows bool
 var preserveProjects bool
 var cascadeDependencies bool
+var cascadeDepth int
+var cascadeEdgeKinds []string
 var defaultApplyRequirements []string
 var numExecutors int64
 var projectHclFiles []string
@@ -940,18 +1268,30 @@ var createHclProjectExternalChilds bool
 var useProjectMarkers bool
 var executionOrderGroups bool
 var dependsOn bool
+var keepGoing bool
+var onParseError string
+var conservativeWhenModified []string
+var debug bool
 
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Makes atlantis config",
 	Long:  `Logs Yaml representing Atlantis config to stderr`,
-	// Test is needed to confirm that if --depends on is set, --create-project-name is also set.
-	PreRun: func(cmd *cobra.Command, args []string) {
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags not given on the command line are read from the environment and the config file
+		if err := loadToolConfig(cmd.Flags()); err != nil {
+			return err
+		}
+
+		// Test is needed to confirm that if --depends on is set, --create-project-name is also set.
+		// A name template names the projects as well.
 		dependsOn, _ := cmd.Flags().GetBool("depends-on")
-		if dependsOn {
+		nameTemplate, _ := cmd.Flags().GetString("project-name-template")
+		if dependsOn && nameTemplate == "" {
 			cmd.MarkFlagRequired("create-project-name")
 		}
+		return nil
 	},
 	RunE: main,
 }
@@ -966,8 +1306,11 @@ func init() {
 
 	generateCmd.PersistentFlags().BoolVar(&autoPlan, "autoplan", false, "Enable auto plan. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&autoMerge, "automerge", false, "Enable auto merge. Default is disabled")
-	generateCmd.PersistentFlags().BoolVar(&ignoreParentTerragrunt, "ignore-parent-terragrunt", true, "Ignore parent terragrunt configs (those which don't reference a terraform module). Default is enabled")
-	generateCmd.PersistentFlags().BoolVar(&createParentProject, "create-parent-project", false, "Create a project for the parent terragrunt configs (those which don't reference a terraform module). Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&ignoreParentTerragrunt, "ignore-parent-terragrunt", true, "Ignore parent terragrunt configs (those included by other configs, or with atlantis_parent = true). Default is enabled")
+	generateCmd.PersistentFlags().BoolVar(&createParentProject, "create-parent-project", false, "Create a project for the parent terragrunt configs (those included by other configs, or with atlantis_parent = true). Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&parentProjectChildren, "parent-project-children", false, "Adds all hcl, tf and tofu files below the directory of a parent project to its when_modified. Requires --create-parent-project. Default is disabled")
+	generateCmd.PersistentFlags().StringVar(&parentWorkflow, "parent-workflow", "", "Workflow of the projects created by --create-parent-project, taking precedence over the locals of the parent. Default is the workflow of other projects")
+	generateCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Logs debug messages, such as why each config was classified as a parent or a module. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&ignoreDependencyBlocks, "ignore-dependency-blocks", false, "When true, dependencies found in `dependency` blocks will be ignored")
 	generateCmd.PersistentFlags().BoolVar(&parallel, "parallel", true, "Enables plans and applys to happen in parallel. Default is enabled")
 	generateCmd.PersistentFlags().BoolVar(&createWorkspace, "create-workspace", false, "Use different workspace for each project. Default is use default workspace")
@@ -975,19 +1318,41 @@ func init() {
 	generateCmd.PersistentFlags().BoolVar(&preserveWorkflows, "preserve-workflows", true, "Preserves workflows from old output files. Default is true")
 	generateCmd.PersistentFlags().BoolVar(&preserveProjects, "preserve-projects", false, "Preserves projects from old output files to enable incremental builds. Default is false")
 	generateCmd.PersistentFlags().BoolVar(&cascadeDependencies, "cascade-dependencies", true, "When true, dependencies will cascade, meaning that a module will be declared to depend not only on its dependencies, but all dependencies of its dependencies all the way down. Default is true")
+	generateCmd.PersistentFlags().IntVar(&cascadeDepth, "cascade-depth", -1, "Number of levels dependencies cascade into when --cascade-dependencies is enabled. 1 adds the dependencies of direct dependencies, -1 is unlimited. Can be overridden by locals. Default is -1")
+	generateCmd.PersistentFlags().StringSliceVar(&cascadeEdgeKinds, "cascade-edge-kinds", []string{"include", "extra", "dependency", "source", "var-file", "local-module"}, "Comma-separated kinds of dependencies that cascade: include, extra, dependency, source, var-file, local-module. Default is all kinds")
 	generateCmd.PersistentFlags().StringVar(&defaultWorkflow, "workflow", "", "Name of the workflow to be customized in the atlantis server. Default is to not set")
-	generateCmd.PersistentFlags().StringSliceVar(&defaultApplyRequirements, "apply-requirements", []string{}, "Requirements that must be satisfied before `atlantis apply` can be run. Currently the only supported requirements are `approved` and `mergeable`. Can be overridden by locals")
+	generateCmd.PersistentFlags().StringSliceVar(&defaultApplyRequirements, "apply-requirements", []string{}, "Requirements that must be satisfied before `atlantis apply` can be run. Supported requirements are `approved`, `mergeable` and `undiverged`. Can be overridden by locals")
 	generateCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Path of the file where configuration will be generated. Default is not to write to file")
 	generateCmd.PersistentFlags().StringSliceVar(&filterPaths, "filter", []string{}, "Comma-separated paths or glob expressions to the directories you want scope down the config for. Default is all files in root.")
 	generateCmd.PersistentFlags().StringVar(&gitRoot, "root", pwd, "Path to the root directory of the git repo you want to build config for. Default is current dir")
+	generateCmd.PersistentFlags().StringVar(&repoRootPath, "repo-root", "", "Path to the root directory of the git repo, which project dirs are relative to. Default is the closest parent of the first --scan-root with a .git directory, or --root")
+	generateCmd.PersistentFlags().StringSliceVar(&scanRootPaths, "scan-root", []string{}, "Comma-separated paths of directories in the repo to look for modules in. Can be repeated, and the projects of all of them are merged into one config. Default is --root")
+	generateCmd.PersistentFlags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follows symlinked directories while looking for modules and project hcl files. Paths in the output stay those of the links. Default is false")
 	generateCmd.PersistentFlags().StringVar(&defaultTerraformVersion, "terraform-version", "", "Default terraform version to specify for all modules. Can be overriden by locals")
 	generateCmd.PersistentFlags().Int64Var(&numExecutors, "num-executors", 15, "Number of executors used for parallel generation of projects. Default is 15")
-	generateCmd.PersistentFlags().StringSliceVar(&projectHclFiles, "project-hcl-files", []string{}, "Comma-separated names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for. Disables the --filter flag")
+	generateCmd.PersistentFlags().StringSliceVar(&projectHclFiles, "project-hcl-files", []string{}, "Comma-separated names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for")
+	generateCmd.PersistentFlags().BoolVar(&projectHclPrecedence, "project-hcl-precedence", false, "Modules below several project hcl files belong to the one listed first in --project-hcl-files instead of the innermost one. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&preciseHclProjectWhenModified, "precise-hcl-project-when-modified", false, "The when_modified of projects of --project-hcl-files lists the files of their modules and their dependencies, instead of all hcl, tf and tofu files below them. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&compressHclProjectWhenModified, "compress-hcl-project-when-modified", false, "Merges the patterns of --precise-hcl-project-when-modified for all subdirectories of a directory into one with a wildcard. The wildcard also matches directories added after generation, until the config is generated again. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&createHclProjectChilds, "create-hcl-project-childs", false, "Creates Atlantis projects for terragrunt child modules below the directories containing the HCL files defined in --project-hcl-files")
 	generateCmd.PersistentFlags().BoolVar(&createHclProjectExternalChilds, "create-hcl-project-external-childs", true, "Creates Atlantis projects for terragrunt child modules outside the directories containing the HCL files defined in --project-hcl-files")
 	generateCmd.PersistentFlags().BoolVar(&useProjectMarkers, "use-project-markers", false, "Creates Atlantis projects only for project hcl files with locals: atlantis_project = true")
 	generateCmd.PersistentFlags().BoolVar(&executionOrderGroups, "execution-order-groups", false, "Computes execution_order_groups for projects")
 	generateCmd.PersistentFlags().BoolVar(&dependsOn, "depends-on", false, "Computes depends_on for projects. Requires --create-project-name.")
+	generateCmd.PersistentFlags().BoolVar(&keepGoing, "keep-going", false, "Keeps generating projects when modules fail to parse. Failures are summarized at the end, and the exit code is non-zero. Default is false")
+	generateCmd.PersistentFlags().StringVar(&toolConfigPath, "config", "", "Path of a YAML file setting any of these flags. Default is "+toolConfigFileName+" in the --root directory, if it exists")
+	generateCmd.PersistentFlags().StringVar(&onParseError, "on-parse-error", onParseErrorFail, "What to do with modules that fail to parse: fail, skip, or conservative to create a project that is planned on any change to the module directory, its subdirectories or the hcl files above it. Default is fail")
+	generateCmd.PersistentFlags().StringVar(&onOutsideDependency, "on-outside-dependency", onOutsideDependencyWarn, "What to do with dependencies outside of the repo root, which Atlantis can never match: warn, error, or drop to leave them out of when_modified and not cascade into them. All of them are reported. Default is warn")
+	generateCmd.PersistentFlags().StringVar(&projectNameTemplate, "project-name-template", "", "Go template for project names, such as '{{ .Locals.account_name }}-{{ .Locals.region }}', with access to .Dir, .DirParts, .Name and all .Locals of the module. Implies --create-project-name")
+	generateCmd.PersistentFlags().StringVar(&workspaceTemplate, "workspace-template", "", "Go template for workspaces, with the same data as --project-name-template. Implies --create-workspace")
+	generateCmd.PersistentFlags().IntVar(&maxNameLength, "max-name-length", 0, "Maximum length of project names and workspaces. Longer ones are cut, and end in a hash of the full name to stay unique. Default is 0, for no limit")
+	generateCmd.PersistentFlags().StringVar(&envMatrixPath, "env-matrix", "", "Path of a YAML file with named sets of environment variables. Every module is evaluated once per set, creating one project per set")
+	generateCmd.PersistentFlags().BoolVar(&cleanEnv, "clean-env", false, "Evaluates modules with only the environment variables from --env-file and --set-env, instead of those of the process. Default is false")
+	generateCmd.PersistentFlags().StringSliceVar(&envFiles, "env-file", []string{}, "Comma-separated paths of dotenv files with environment variables to evaluate modules with. Later files override earlier ones")
+	generateCmd.PersistentFlags().StringArrayVar(&setEnvVars, "set-env", []string{}, "Environment variable to evaluate modules with, as NAME=VALUE. Can be repeated, and overrides --env-file")
+	generateCmd.PersistentFlags().BoolVar(&sandbox, "sandbox", false, "Replaces run_cmd, sops_decrypt_file and the get_aws_* functions by stubs returning placeholders, so that no commands run and no cloud APIs are called. Default is false")
+	generateCmd.PersistentFlags().StringVar(&sandboxStubsPath, "sandbox-stubs", "", "Path of a YAML file with the values returned by the stubs of --sandbox. Implies --sandbox")
+	generateCmd.PersistentFlags().StringSliceVar(&conservativeWhenModified, "conservative-when-modified", []string{}, "Comma-separated patterns, relative to the root, that are added to the when_modified of conservative projects created by --on-parse-error=conservative")
 }
 
 // Runs a set of arguments, returning the output
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
@@ -9,8 +10,10 @@ import (
 	"testing"
 
 	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/sync/singleflight"
+	"github.com/stretchr/testify/require"
 )
 
 // Test directory constants
@@ -29,12 +32,20 @@ func resetForRun() error {
 
 	// reset caches
 	getDependenciesCache = newGetDependenciesCache()
-	requestGroup = singleflight.Group{}
-	// reset flags
+	moduleGraph = newDependencyGraph()
+	// reset flags, including whether they were given on the command line before, and whether
+	// --depends-on marked them as required
+	generateCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		f.Changed = false
+		delete(f.Annotations, cobra.BashCompOneRequiredFlag)
+	})
+	toolConfigPath = ""
 	gitRoot = pwd
 	autoPlan = false
 	autoMerge = false
 	cascadeDependencies = true
+	cascadeDepth = -1
+	cascadeEdgeKinds = []string{"include", "extra", "dependency", "source", "var-file", "local-module"}
 	ignoreParentTerragrunt = true
 	ignoreDependencyBlocks = false
 	parallel = true
@@ -53,6 +64,30 @@ func resetForRun() error {
 	useProjectMarkers = false
 	executionOrderGroups = false
 	dependsOn = false
+	keepGoing = false
+	onParseError = "fail"
+	conservativeWhenModified = []string{}
+	projectNameTemplate = ""
+	workspaceTemplate = ""
+	maxNameLength = 0
+	envMatrixPath = ""
+	cleanEnv = false
+	envFiles = []string{}
+	setEnvVars = []string{}
+	evalEnv = nil
+	sandbox = false
+	sandboxStubsPath = ""
+	repoRootPath = ""
+	scanRootPaths = []string{}
+	followSymlinks = false
+	onOutsideDependency = onOutsideDependencyWarn
+	createParentProject = false
+	parentProjectChildren = false
+	parentWorkflow = ""
+	debug = false
+	projectHclPrecedence = false
+	preciseHclProjectWhenModified = false
+	compressHclProjectWhenModified = false
 
 	return nil
 }
@@ -189,7 +224,8 @@ func TestNonStringErrorOnExtraDeclaredDependencies(t *testing.T) {
 	})
 	err = rootCmd.Execute()
 
-	expectedError := "extra_atlantis_dependencies contains non-string value at position 4"
+	configPath, _ := filepath.Abs(filepath.Join("..", "test/fixtures_errors", "extra_dependency_error", "child", "terragrunt.hcl"))
+	expectedError := "invalid locals in " + configPath + ": extra_atlantis_dependencies contains non-string value at position 4"
 	if err == nil || err.Error() != expectedError {
 		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
 		return
@@ -197,6 +233,214 @@ func TestNonStringErrorOnExtraDeclaredDependencies(t *testing.T) {
 	return
 }
 
+func TestKeepGoing(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	filename := filepath.Join(testArtifactsDir, fmt.Sprintf("%d.yaml", rand.Int()))
+	defer os.Remove(filename)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test/fixtures_errors", "keep_going"),
+		"--output",
+		filename,
+		"--keep-going",
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "failed to create projects for 2 module(s)", err.Error())
+
+	// Projects of the modules that did not fail are still written
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	expected, err := os.ReadFile(filepath.Join(testReferenceOutputs, "keep_going.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(content))
+}
+
+func TestFailingModuleWithoutKeepGoing(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test/fixtures_errors", "keep_going"),
+		"--keep-going=false",
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "module(s)")
+}
+
+func TestOnParseErrorSkip(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "on_parse_error_skip.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test/fixtures_errors", "keep_going"),
+		"--on-parse-error=skip",
+	})
+}
+
+func TestOnParseErrorConservative(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "on_parse_error_conservative.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test/fixtures_errors", "keep_going"),
+		"--on-parse-error=conservative",
+	})
+}
+
+func TestOnParseErrorInvalid(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test/fixtures_errors", "keep_going"),
+		"--on-parse-error=ignore",
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown value "ignore" for --on-parse-error`)
+}
+
+func TestCreateConservativeProject(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+	defer resetForRun()
+
+	gitRoot = "/repo/"
+	createProjectName = true
+	conservativeWhenModified = []string{"modules/**/*.tf", "/versions.hcl"}
+
+	project, err := createConservativeProject(context.Background(), "/repo/envs/prod/app/terragrunt.hcl", fmt.Errorf("broken\nmore details"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "envs/prod/app", project.Dir)
+	assert.Equal(t, "envs_prod_app", project.Name)
+	assert.Equal(t, []string{
+		"*",
+		"**/*",
+		"../*.hcl",
+		"../../*.hcl",
+		"../../../*.hcl",
+		"../../../modules/**/*.tf",
+		"../../../versions.hcl",
+	}, project.Autoplan.WhenModified)
+	assert.Equal(t, []string{"failed to parse, using a conservative when_modified: broken"}, project.warnings)
+}
+
+func TestAnnotateWarnings(t *testing.T) {
+	projects := []AtlantisProject{
+		{Dir: "a"},
+		{Dir: "b", warnings: []string{"first", "second"}},
+	}
+	input := `automerge: false
+projects:
+- autoplan:
+    enabled: false
+  dir: a
+- autoplan:
+    enabled: false
+  dir: b
+version: 3
+`
+	expected := `automerge: false
+projects:
+- autoplan:
+    enabled: false
+  dir: a
+- autoplan:
+    enabled: false
+  dir: b # WARNING: first; second
+version: 3
+`
+	annotated, err := annotateWarnings(input, projects)
+	require.NoError(t, err)
+	assert.Equal(t, expected, annotated)
+
+	// Warnings go by the position of the project, not by the text of its lines
+	projects = append(projects, AtlantisProject{Dir: "c", warnings: []string{"third"}})
+	input = `projects:
+- dir: a
+- {dir: b, name: "dir: a"}
+- dir: c
+  name: |-
+    - dir: b
+`
+	expected = `projects:
+- dir: a
+- {dir: b, name: "dir: a"} # WARNING: first; second
+- dir: c # WARNING: third
+  name: |-
+    - dir: b
+`
+	annotated, err = annotateWarnings(input, projects)
+	require.NoError(t, err)
+	assert.Equal(t, expected, annotated)
+}
+
+func TestInvalidApplyRequirementsFlag(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join(testFixturesDir, "basic_module"),
+		"--apply-requirements=approved,aproved",
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, `unknown requirement "aproved" in --apply-requirements, must be one of: approved, mergeable, undiverged`, err.Error())
+}
+
+func TestToolConfigFile(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "tool_config_file.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "tool_config"),
+	})
+}
+
+func TestToolConfigPrecedence(t *testing.T) {
+	// The environment overrides the file, and the command line overrides both
+	t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_WORKFLOW", "workflowFromEnv")
+	t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_APPLY_REQUIREMENTS", "undiverged")
+	t.Setenv("TERRAGRUNT_ATLANTIS_CONFIG_AUTOPLAN", "true")
+
+	runTest(t, filepath.Join(testReferenceOutputs, "tool_config_precedence.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "tool_config"),
+		"--autoplan=false",
+	})
+}
+
+func TestProjectRules(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "project_rules.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "project_rules"),
+	})
+}
+
+func TestToolConfigFileFromFlag(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	configPath := filepath.Join("..", "test/fixtures_errors", "tool_config_unknown_key.yaml")
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join(testFixturesDir, "basic_module"),
+		"--config",
+		configPath,
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "invalid config file "+configPath+`: "cascade-dependencie", did you mean "cascade-dependencies"?`, err.Error())
+}
+
 func TestLocalTerraformModuleSource(t *testing.T) {
 	runTest(t, filepath.Join(testReferenceOutputs, "local_terraform_module.yaml"), []string{
 		"--root",
@@ -458,6 +702,73 @@ func TestChainedDependenciesHiddenBehindFlag(t *testing.T) {
 	})
 }
 
+func TestCascadeDepthLocals(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "cascade_depth.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "cascade_depth"),
+	})
+}
+
+func TestCascadeDepthFlag(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "cascade_depth_flag.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "cascade_depth"),
+		"--cascade-depth=1",
+	})
+}
+
+// projectWhenModified generates the config for the fixture `fixture` with `args`, and returns the
+// `when_modified` of the project of `dir`
+func projectWhenModified(t *testing.T, fixture string, dir string, args ...string) []string {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	filename := filepath.Join(testArtifactsDir, fmt.Sprintf("%d.yaml", rand.Int()))
+	defer os.Remove(filename)
+
+	contentBytes, err := RunWithFlags(filename, append([]string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join(testFixturesDir, fixture),
+	}, args...))
+	require.NoError(t, err)
+
+	content := &AtlantisConfig{}
+	require.NoError(t, yaml.Unmarshal(contentBytes, content))
+	for _, project := range content.Projects {
+		if project.Dir == dir {
+			return project.Autoplan.WhenModified
+		}
+	}
+	t.Fatalf("No project for %s", dir)
+	return nil
+}
+
+func TestCascadeDirectMatchesFlag(t *testing.T) {
+	// frontend_direct only differs from frontend by `atlantis_cascade = "direct"`
+	assert.Equal(t,
+		projectWhenModified(t, "cascade_depth", "frontend", "--cascade-depth=0"),
+		projectWhenModified(t, "cascade_depth", "frontend_direct"),
+	)
+}
+
+func TestCascadeEdgeKinds(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "cascade_edge_kinds.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "cascade_depth"),
+		"--cascade-edge-kinds=dependency",
+	})
+}
+
+func TestAtlantisObjectLocal(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "atlantis_object_local.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "atlantis_object_local"),
+	})
+}
+
 func TestApplyRequirementsLocals(t *testing.T) {
 	runTest(t, filepath.Join(testReferenceOutputs, "apply_overrides.yaml"), []string{
 		"--root",
@@ -515,6 +826,81 @@ func TestFilterGlobFlagWithInfraLiveMySql(t *testing.T) {
 	})
 }
 
+func TestFilterFlagWithProjectHclFiles(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "filterProjectHclInfraLiveQA.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "terragrunt-infrastructure-live-example"),
+		"--project-hcl-files=env.hcl",
+		"--filter",
+		filepath.Join(testFixturesDir, "terragrunt-infrastructure-live-example", "non-prod", "us-east-1", "qa", "mysql"),
+	})
+}
+
+func TestFilterGlobWithProjectHclChilds(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "filterProjectHclChildsInfraLiveMySQL.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "terragrunt-infrastructure-live-example"),
+		"--project-hcl-files=env.hcl",
+		"--create-hcl-project-childs",
+		"--filter",
+		filepath.Join(testFixturesDir, "terragrunt-infrastructure-live-example", "non-prod", "*", "*", "mysql"),
+	})
+}
+
+func TestPreservingOldProjectHclProjects(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join(testArtifactsDir, fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// The project of qa is outdated, and the one of prod is outside of the filter
+	contents := []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+  dir: non-prod/us-east-1/qa
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '**/*.hcl'
+  dir: prod/us-east-1/prod
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--preserve-projects",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join(testFixturesDir, "terragrunt-infrastructure-live-example"),
+		"--project-hcl-files=env.hcl",
+		"--filter",
+		filepath.Join(testFixturesDir, "terragrunt-infrastructure-live-example", "non-prod", "us-east-1", "qa"),
+	})
+	if err != nil {
+		t.Error("Failed to read file")
+		return
+	}
+
+	referenceContents, err := os.ReadFile(filepath.Join(testReferenceOutputs, "filterProjectHclPreserved.yaml"))
+	if err != nil {
+		t.Error("Failed to read reference output file")
+		return
+	}
+
+	if string(content) != string(referenceContents) {
+		t.Errorf("Content did not match reference output file.\n\nExpected Content: %s\n\nContent: %s", string(referenceContents), string(content))
+	}
+}
+
 func TestMultipleIncludes(t *testing.T) {
 	runTest(t, filepath.Join(testReferenceOutputs, "multiple_includes.yaml"), []string{
 		"--root",
@@ -658,6 +1044,46 @@ func TestEnvHCLProjectsAllChilds(t *testing.T) {
 	})
 }
 
+func TestNestedProjectHcl(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "nested_project_hcl.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "nested_project_hcl"),
+		"--project-hcl-files=region.hcl,stack.hcl",
+		"--create-hcl-project-external-childs=false",
+	})
+}
+
+func TestNestedProjectHclPrecedence(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "nested_project_hcl_precedence.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "nested_project_hcl"),
+		"--project-hcl-files=region.hcl,stack.hcl",
+		"--create-hcl-project-external-childs=false",
+		"--project-hcl-precedence",
+	})
+}
+
+func TestPreciseHclProjectWhenModified(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "nested_project_hcl_precise.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "nested_project_hcl"),
+		"--project-hcl-files=region.hcl,stack.hcl",
+		"--create-hcl-project-external-childs=false",
+		"--precise-hcl-project-when-modified",
+	})
+}
+
+func TestCompressHclProjectWhenModified(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "nested_project_hcl_compressed.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "nested_project_hcl"),
+		"--project-hcl-files=region.hcl,stack.hcl",
+		"--create-hcl-project-external-childs=false",
+		"--precise-hcl-project-when-modified",
+		"--compress-hcl-project-when-modified",
+	})
+}
+
 func TestEnvHCLProjectMarker(t *testing.T) {
 	runTest(t, filepath.Join(testReferenceOutputs, "project_marker.yaml"), []string{
 		"--root",
@@ -710,7 +1136,7 @@ func TestCacheFunctions(t *testing.T) {
 
 	// Test cache set and get operations
 	testOutput := getDependenciesOutput{
-		dependencies: []string{"dep1", "dep2"},
+		dependencies: []dependencyEdge{{"dep1", dependencyKindDependency}, {"dep2", dependencyKindExtra}},
 		err:          nil,
 	}
 
@@ -957,3 +1383,335 @@ func TestSliceUnion(t *testing.T) {
 		})
 	}
 }
+
+func TestProjectNameTemplates(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "name_templates.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "name_templates"),
+		"--project-name-template",
+		"{{ .Locals.account_name }}-{{ .Locals.env }}-{{ .Locals.region }}-{{ index .DirParts 2 }}",
+		"--workspace-template",
+		"{{ .Locals.env }}",
+	})
+}
+
+func TestMaxNameLength(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "name_templates_truncated.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "name_templates"),
+		"--create-project-name",
+		"--max-name-length",
+		"20",
+	})
+}
+
+func TestProjectNameTemplateErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			"collision",
+			[]string{"--project-name-template", "{{ .Locals.account_name }}-{{ .Locals.region }}"},
+			"project names must be unique:\n  project name \"acme-eu-west-1\" is used by prod/eu-west-1/vpc, staging/eu-west-1/vpc",
+		},
+		{
+			"missing local",
+			[]string{"--project-name-template", "{{ .Locals.nope }}"},
+			`map has no entry for key "nope"`,
+		},
+		{
+			"invalid template",
+			[]string{"--workspace-template", "{{ .Dir"},
+			"invalid --workspace-template",
+		},
+		{
+			"too short",
+			[]string{"--create-project-name", "--max-name-length", "5"},
+			"invalid --max-name-length 5, must be 0 or more than 9",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := resetForRun()
+			require.NoError(t, err)
+
+			rootCmd.SetArgs(append([]string{
+				"generate",
+				"--root",
+				filepath.Join(testFixturesDir, "name_templates"),
+			}, c.args...))
+			err = rootCmd.Execute()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
+
+func TestExplicitProjectNames(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "explicit_names.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "explicit_names"),
+		"--create-project-name",
+		"--depends-on",
+	})
+}
+
+func TestDuplicateExplicitProjectNames(t *testing.T) {
+	err := resetForRun()
+	require.NoError(t, err)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test", "fixtures_errors", "duplicate_project_names"),
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "project names must be unique:\n  project name \"same\" is used by a, b", err.Error())
+}
+
+func TestAtlantisWorkspaces(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "atlantis_workspaces.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "atlantis_workspaces"),
+		"--create-project-name",
+		"--execution-order-groups",
+		"--depends-on",
+	})
+}
+
+func TestAddProjectsPreservesByWorkspace(t *testing.T) {
+	defer func() { preserveProjects = true }()
+	preserveProjects = true
+
+	config := AtlantisConfig{Projects: []AtlantisProject{
+		{Dir: "app", Workspace: "staging", Workflow: "old", preserved: true},
+		{Dir: "app", Workspace: "qa", preserved: true},
+		{Dir: "other", preserved: true},
+	}}
+	addProjects(&config, []AtlantisProject{
+		{Dir: "app", Workspace: "staging", Workflow: "new"},
+		{Dir: "app", Workspace: "prod"},
+	}, "project", "app/terragrunt.hcl")
+
+	// The qa workspace is no longer produced by the module, while other directories are untouched
+	assert.Equal(t, []AtlantisProject{
+		{Dir: "app", Workspace: "staging", Workflow: "new"},
+		{Dir: "other", preserved: true},
+		{Dir: "app", Workspace: "prod"},
+	}, config.Projects)
+}
+
+func TestEnvMatrix(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "env_matrix.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "env_matrix"),
+		"--env-matrix",
+		filepath.Join(testFixturesDir, "env_matrix", "env_matrix.yaml"),
+		"--create-project-name",
+		"--execution-order-groups",
+		"--depends-on",
+	})
+}
+
+func TestCleanEnv(t *testing.T) {
+	// Ignored with --clean-env, which keeps the default of the module
+	t.Setenv("TG_AUTOPLAN", "false")
+
+	runTest(t, filepath.Join(testReferenceOutputs, "clean_env.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "clean_env"),
+		"--clean-env",
+		"--env-file",
+		filepath.Join(testFixturesDir, "clean_env", "ci.env"),
+		"--set-env",
+		"TG_TERRAFORM_VERSION=0.15.0",
+	})
+}
+
+func TestSandbox(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "sandbox.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test", "fixtures_errors", "sandbox"),
+		"--sandbox",
+	})
+}
+
+func TestSandboxStubs(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "sandbox_stubs.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test", "fixtures_errors", "sandbox"),
+		"--sandbox-stubs",
+		filepath.Join("..", "test", "fixtures_errors", "sandbox", "stubs.yaml"),
+	})
+}
+
+func TestIncludeMergeStrategies(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "include_merge_strategies.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "include_merge_strategies"),
+	})
+}
+
+func TestInheritFrom(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "inherit_from.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "inherit_from"),
+	})
+}
+
+func TestInheritFromIdenticalLocals(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "inherit_from_identical.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "inherit_from_identical"),
+	})
+}
+
+func TestRepoRootFunctions(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "repo_root_functions.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "repo_root_functions"),
+	})
+}
+
+func TestScanRoots(t *testing.T) {
+	root := filepath.Join(testFixturesDir, "scan_roots")
+	runTest(t, filepath.Join(testReferenceOutputs, "scan_roots.yaml"), []string{
+		"--repo-root",
+		root,
+		"--scan-root",
+		filepath.Join(root, "infra", "live"),
+		"--scan-root",
+		filepath.Join(root, "platform", "live"),
+	})
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "symlinks.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "symlinks"),
+		"--follow-symlinks",
+	})
+}
+
+func TestOutsideDependencies(t *testing.T) {
+	root := filepath.Join(testFixturesDir, "outside_root", "repo")
+
+	runTest(t, filepath.Join(testReferenceOutputs, "outside_root_warn.yaml"), []string{
+		"--root",
+		root,
+	})
+	assert.Equal(t, []outsideDependency{
+		{module: "app/terragrunt.hcl", reference: "../../shared/common.tfvars", kind: dependencyKindVarFile},
+		{module: "app/terragrunt.hcl", reference: "../../shared/modules/network/*.tf*", kind: dependencyKindSource},
+		{module: "app/terragrunt.hcl", reference: "../../shared/modules/network/*.tofu*", kind: dependencyKindSource},
+		{module: "app/terragrunt.hcl", reference: "../../shared/vpc/terragrunt.hcl", kind: dependencyKindDependency},
+	}, outsideDeps.sorted())
+
+	runTest(t, filepath.Join(testReferenceOutputs, "outside_root_drop.yaml"), []string{
+		"--root",
+		root,
+		"--on-outside-dependency",
+		"drop",
+	})
+	assert.Len(t, outsideDeps.sorted(), 4)
+}
+
+func TestOutsideDependenciesError(t *testing.T) {
+	require.NoError(t, resetForRun())
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join(testFixturesDir, "outside_root", "repo"),
+		"--on-outside-dependency",
+		"error",
+	})
+	err := rootCmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app/terragrunt.hcl depends on ")
+	assert.Contains(t, err.Error(), "which is outside of the repo root")
+
+	require.NoError(t, resetForRun())
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join(testFixturesDir, "outside_root", "repo"),
+		"--on-outside-dependency",
+		"ignore",
+	})
+	err = rootCmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown value "ignore" for --on-outside-dependency`)
+}
+
+func TestOutsideDependenciesErrorWithOnParseError(t *testing.T) {
+	for _, onParseError := range []string{"skip", "conservative"} {
+		require.NoError(t, resetForRun())
+		rootCmd.SetArgs([]string{
+			"generate",
+			"--root",
+			filepath.Join(testFixturesDir, "outside_root", "repo"),
+			"--on-outside-dependency=error",
+			"--on-parse-error=" + onParseError,
+		})
+		err := rootCmd.Execute()
+		require.Error(t, err, onParseError)
+		assert.Contains(t, err.Error(), "which is outside of the repo root")
+	}
+}
+
+func TestCreateParentProjectKeepsChildWhenModified(t *testing.T) {
+	// Cascading stops at the sourceless shared config either way
+	expected := []string{"*.hcl", "*.tf*", "*.tofu*", "../root.hcl", "../shared/terragrunt.hcl"}
+	assert.Equal(t, expected, projectWhenModified(t, "parent_project_cascade", "app"))
+	assert.Equal(t, expected, projectWhenModified(t, "parent_project_cascade", "app", "--create-parent-project"))
+}
+
+func TestCreateParentProject(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "parent_project.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "parent_project"),
+		"--create-parent-project",
+	})
+}
+
+func TestCreateParentProjectWithChildren(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "parent_project_children.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "parent_project"),
+		"--create-parent-project",
+		"--parent-project-children",
+		"--parent-workflow",
+		"state",
+	})
+}
+
+// The parent is included by a module outside of the filter, which still makes it a parent
+func TestFilterParent(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "filter_parent.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "filter_parent"),
+		"--filter",
+		filepath.Join(testFixturesDir, "filter_parent", "parent"),
+	})
+}
+
+func TestFilterParentProject(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "filter_parent_project.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "filter_parent"),
+		"--filter",
+		filepath.Join(testFixturesDir, "filter_parent", "parent"),
+		"--create-parent-project",
+	})
+}
+
+func TestParentDetection(t *testing.T) {
+	runTest(t, filepath.Join(testReferenceOutputs, "parent_detection.yaml"), []string{
+		"--root",
+		filepath.Join(testFixturesDir, "parent_detection"),
+	})
+}
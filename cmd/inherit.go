@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Attribute added to the configs returned by `read_terragrunt_config`, holding the path of the file they were read
+// from. The configs of terragrunt don't include it, but `atlantis_inherit_from` needs it for `when_modified`.
+const readConfigPathAttribute = "__atlantis_config_path"
+
+// recordingReadTerragruntConfig replaces the `read_terragrunt_config` function of terragrunt, to record the
+// path of every config read while evaluating the module of `ctx` in the config itself
+func recordingReadTerragruntConfig(ctx *config.ParsingContext) function.Function {
+	return function.New(&function.Spec{
+		Params:   []function.Parameter{{Type: cty.String}},
+		VarParam: &function.Parameter{Type: cty.DynamicPseudoType},
+		Type:     function.StaticReturnType(cty.DynamicPseudoType),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if len(args) > 2 {
+				return cty.NilVal, config.WrongNumberOfParamsError{Func: config.FuncNameReadTerragruntConfig, Expected: "1 or 2", Actual: len(args)}
+			}
+			var defaultValue *cty.Value
+			if len(args) == 2 {
+				defaultValue = &args[1]
+			}
+
+			// Configs read by the config being read are evaluated by terragrunt itself, relative to that config
+			inner := *ctx
+			inner.PredefinedFunctions = map[string]function.Function{}
+			for name, fn := range ctx.PredefinedFunctions {
+				if name != config.FuncNameReadTerragruntConfig {
+					inner.PredefinedFunctions[name] = fn
+				}
+			}
+
+			path := args[0].AsString()
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(ctx.TerragruntOptions.TerragruntConfigPath), path)
+			}
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				path = filepath.Join(path, terragruntConfigFile)
+			}
+
+			// The functions about the repo root are relative to the config being read
+			readOptions := *ctx.TerragruntOptions
+			readOptions.WorkingDir = filepath.Dir(path)
+			for name, fn := range repoRootFunctions(&readOptions) {
+				inner.PredefinedFunctions[name] = fn
+			}
+
+			value, err := config.ParseTerragruntConfig(&inner, createLogger(), args[0].AsString(), defaultValue)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			if _, err := os.Stat(path); err != nil {
+				// The default value, as the file doesn't exist
+				return value, nil
+			}
+			return withReadConfigPath(value, filepath.Clean(path)), nil
+		},
+	})
+}
+
+// withReadConfigPath returns the config `readConfig` with the path of its file in readConfigPathAttribute
+func withReadConfigPath(readConfig cty.Value, path string) cty.Value {
+	if !readConfig.IsKnown() || readConfig.IsNull() || !readConfig.Type().IsObjectType() {
+		return readConfig
+	}
+	unmarked, marks := readConfig.Unmark()
+	attributes := unmarked.AsValueMap()
+	if attributes == nil {
+		attributes = map[string]cty.Value{}
+	}
+	attributes[readConfigPathAttribute] = cty.StringVal(path)
+	return cty.ObjectVal(attributes).WithMarks(marks)
+}
+
+// readConfigPath returns the path of the file of a config returned by `read_terragrunt_config`
+func readConfigPath(readConfig cty.Value) (string, bool) {
+	if !readConfig.Type().HasAttribute(readConfigPathAttribute) {
+		return "", false
+	}
+	path, _ := readConfig.GetAttr(readConfigPathAttribute).Unmark()
+	if !path.IsKnown() || path.IsNull() || !path.Type().Equals(cty.String) {
+		return "", false
+	}
+	return path.AsString(), true
+}
+
+// decodeInheritFromLocal decodes the configs of `atlantis_inherit_from`, which are read with
+// `read_terragrunt_config`, into the settings from their locals. Later configs take precedence over earlier
+// ones, and the files of the configs are added to the extra dependencies.
+func decodeInheritFromLocal(name string, value cty.Value) (ResolvedLocals, error) {
+	inherited := ResolvedLocals{}
+	known, err := checkLocal(name, value)
+	if err != nil || !known {
+		return inherited, err
+	}
+	valueType := value.Type()
+	if !valueType.IsListType() && !valueType.IsTupleType() {
+		return inherited, invalidLocalError{name, "must be a list of configs read with read_terragrunt_config, got " + valueType.FriendlyName()}
+	}
+
+	position := 0
+	for it := value.ElementIterator(); it.Next(); position++ {
+		_, readConfig := it.Element()
+		if readConfig.IsNull() || !readConfig.Type().IsObjectType() || !readConfig.Type().HasAttribute("locals") {
+			return inherited, invalidLocalError{name, fmt.Sprintf("[%d] must be a config read with read_terragrunt_config", position)}
+		}
+
+		settings, err := resolveLocals(readConfig.GetAttr("locals"))
+		if err != nil {
+			return inherited, fmt.Errorf("%s[%d]: %w", name, position, err)
+		}
+		if path, ok := readConfigPath(readConfig); ok {
+			settings.ExtraAtlantisDependencies = append([]string{path}, settings.ExtraAtlantisDependencies...)
+		}
+		settings.values = nil
+		settings.parent = nil
+		inherited = mergeResolvedLocals(inherited, settings)
+	}
+
+	return inherited, nil
+}
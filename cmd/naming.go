@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Go templates for the names of projects and their workspaces, from `--project-name-template` and
+// `--workspace-template`. Empty templates keep the names derived from the directory.
+var projectNameTemplate string
+var workspaceTemplate string
+
+// Maximum number of characters of project names and workspaces, from `--max-name-length`. 0 is no limit.
+var maxNameLength int
+
+// Number of hex characters of the hash that ends truncated names
+const nameHashLength = 8
+
+// Parsed `--project-name-template` and `--workspace-template`, set by `parseNameTemplates`
+var projectNameTmpl *template.Template
+var workspaceTmpl *template.Template
+
+// Characters that are not allowed in Terraform Cloud workspace names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Functions available to the name templates, next to the builtin ones
+var nameTemplateFuncs = template.FuncMap{
+	"sanitize": func(s string) string { return invalidNameChars.ReplaceAllString(s, "_") },
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"replace":  func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"join":     func(sep string, parts []string) string { return strings.Join(parts, sep) },
+}
+
+// nameTemplateData is what the name templates are rendered with
+type nameTemplateData struct {
+	// Directory of the project, relative to the root
+	Dir string
+
+	// Dir split at every slash
+	DirParts []string
+
+	// The name derived from the directory, such as `prod_eu-west-1_vpc`
+	Name string
+
+	// The workspace from `atlantis_workspaces`, empty for modules without them
+	Workspace string
+
+	// Name of the environment of `--env-matrix`, empty without one
+	Env string
+
+	// All locals of the module and its includes, converted to strings, numbers, bools, lists and maps
+	Locals map[string]interface{}
+}
+
+// parseNameTemplates parses the name templates from the flags, and validates `--max-name-length`
+func parseNameTemplates() error {
+	projectNameTmpl, workspaceTmpl = nil, nil
+
+	var err error
+	if projectNameTemplate != "" {
+		projectNameTmpl, err = template.New("project-name-template").Option("missingkey=error").Funcs(nameTemplateFuncs).Parse(projectNameTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid --project-name-template: %w", err)
+		}
+	}
+	if workspaceTemplate != "" {
+		workspaceTmpl, err = template.New("workspace-template").Option("missingkey=error").Funcs(nameTemplateFuncs).Parse(workspaceTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid --workspace-template: %w", err)
+		}
+	}
+
+	if maxNameLength < 0 || (maxNameLength > 0 && maxNameLength <= nameHashLength+1) {
+		return fmt.Errorf("invalid --max-name-length %d, must be 0 or more than %d", maxNameLength, nameHashLength+1)
+	}
+	return nil
+}
+
+// nameProject sets the name and workspace of a project. They are taken from the `atlantis_project_name` and
+// `atlantis_workspace` locals, or else derived from its directory, unless templates are given, and are
+// truncated to `--max-name-length`. `workspace` is the workspace from `atlantis_workspaces`, if any, which
+// always becomes the workspace of the project.
+func nameProject(project *AtlantisProject, locals ResolvedLocals, workspace string) error {
+	nameProjectAfterDir(project, workspace)
+
+	data := nameTemplateData{
+		Dir:       project.Dir,
+		DirParts:  strings.Split(project.Dir, "/"),
+		Name:      dirProjectName(project.Dir, project.env, workspace),
+		Workspace: workspace,
+		Env:       project.env,
+	}
+	renderWorkspace := workspace == "" && locals.Workspace == "" && workspaceTmpl != nil
+	if (projectNameTmpl != nil && locals.ProjectName == "") || renderWorkspace {
+		data.Locals = localsForTemplates(locals)
+	}
+
+	switch {
+	case locals.ProjectName != "":
+		if err := checkExplicitName("atlantis_project_name", locals.ProjectName, project.Dir); err != nil {
+			return err
+		}
+		project.Name = locals.ProjectName
+	case projectNameTmpl != nil:
+		name, err := renderName(projectNameTmpl, data)
+		if err != nil {
+			return err
+		}
+		project.Name = name
+	}
+
+	switch {
+	case workspace != "":
+	case locals.Workspace != "":
+		if err := checkExplicitName("atlantis_workspace", locals.Workspace, project.Dir); err != nil {
+			return err
+		}
+		project.Workspace = locals.Workspace
+	case renderWorkspace:
+		name, err := renderName(workspaceTmpl, data)
+		if err != nil {
+			return err
+		}
+		project.Workspace = name
+	}
+
+	return nil
+}
+
+// checkExplicitName fails on names from locals that are longer than `--max-name-length`, as cutting them
+// would defeat the point of choosing them
+func checkExplicitName(local string, name string, dir string) error {
+	if maxNameLength > 0 && len([]rune(name)) > maxNameLength {
+		return fmt.Errorf("%s %q of %s is longer than --max-name-length %d", local, name, dir, maxNameLength)
+	}
+	return nil
+}
+
+// nameProjectAfterDir sets the name and workspace of a project to the ones derived from its directory
+func nameProjectAfterDir(project *AtlantisProject, workspace string) {
+	projectName := truncateName(dirProjectName(project.Dir, project.env, workspace))
+
+	if createProjectName || projectNameTmpl != nil {
+		project.Name = projectName
+	}
+
+	switch {
+	case workspace != "":
+		project.Workspace = workspace
+	case createWorkspace || workspaceTmpl != nil:
+		project.Workspace = projectName
+	case project.env != "":
+		// Projects of the same directory need different workspaces
+		project.Workspace = project.env
+	}
+}
+
+// dirProjectName derives a name from the directory of a project, its environment of `--env-matrix`, and its
+// workspace from `atlantis_workspaces`
+func dirProjectName(dir string, env string, workspace string) string {
+	// Terraform Cloud limits the workspace names to be less than 90 characters
+	// with letters, numbers, -, and _
+	// https://www.terraform.io/docs/cloud/workspaces/naming.html
+	// It is not clear from documentation whether the normal workspaces have those limitations
+	// However a workspace 97 chars long has been working perfectly.
+	// We are going to use the same name for both workspace & project name as it is unique.
+	name := invalidNameChars.ReplaceAllString(dir, "_")
+	if env != "" {
+		name += "_" + env
+	}
+	if workspace != "" {
+		name += "_" + invalidNameChars.ReplaceAllString(workspace, "_")
+	}
+	return name
+}
+
+// renderName renders a name template, failing on empty names
+func renderName(tmpl *template.Template, data nameTemplateData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render --%s for %s: %w", tmpl.Name(), data.Dir, err)
+	}
+
+	name := strings.TrimSpace(sb.String())
+	if name == "" {
+		return "", fmt.Errorf("--%s rendered an empty name for %s", tmpl.Name(), data.Dir)
+	}
+	return truncateName(name), nil
+}
+
+// localsForTemplates converts the evaluated locals to plain Go values. Locals that are not known, such
+// as those depending on outputs of other modules, are left out.
+func localsForTemplates(locals ResolvedLocals) map[string]interface{} {
+	converted := make(map[string]interface{}, len(locals.values))
+	for name, value := range locals.values {
+		value, _ = value.UnmarkDeep()
+		if !value.IsWhollyKnown() {
+			continue
+		}
+
+		encoded, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			continue
+		}
+		converted[name] = decoded
+	}
+	return converted
+}
+
+// truncateName shortens names longer than `--max-name-length`. The end of the name is replaced by a hash of
+// the full name, so that names with a common prefix stay unique.
+func truncateName(name string) string {
+	runes := []rune(name)
+	if maxNameLength <= 0 || len(runes) <= maxNameLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	prefix := strings.TrimRight(string(runes[:maxNameLength-nameHashLength-1]), "-_")
+	return prefix + "-" + hash
+}
+
+// checkUniqueNames returns an error listing every project name used by more than one project, and every
+// workspace used more than once in the same directory by projects that are not all named, as Atlantis rejects
+// such configs
+func checkUniqueNames(projects []AtlantisProject) error {
+	namesToDirs := map[string][]string{}
+	workspaceCounts := map[[2]string]int{}
+	unnamedWorkspaces := map[[2]string]bool{}
+	for _, project := range projects {
+		key := [2]string{project.Dir, project.Workspace}
+		if project.Name != "" {
+			namesToDirs[project.Name] = append(namesToDirs[project.Name], project.Dir)
+		} else {
+			unnamedWorkspaces[key] = true
+		}
+		workspaceCounts[key]++
+	}
+
+	collisions := []string{}
+	for name, dirs := range namesToDirs {
+		if len(dirs) > 1 {
+			collisions = append(collisions, fmt.Sprintf("project name %q is used by %s", name, strings.Join(dirs, ", ")))
+		}
+	}
+	for key, count := range workspaceCounts {
+		// Projects with unique names can share a directory and workspace
+		if count > 1 && unnamedWorkspaces[key] {
+			workspace := key[1]
+			if workspace == "" {
+				workspace = "default"
+			}
+			collisions = append(collisions, fmt.Sprintf("workspace %q is used by %d projects in %s", workspace, count, key[0]))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Strings(collisions)
+	return fmt.Errorf("project names must be unique:\n  %s", strings.Join(collisions, "\n  "))
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTruncateName(t *testing.T) {
+	defer func() { maxNameLength = 0 }()
+
+	maxNameLength = 0
+	assert.Equal(t, "a_rather_long_project_name", truncateName("a_rather_long_project_name"))
+
+	maxNameLength = 20
+	assert.Equal(t, "short_name", truncateName("short_name"))
+
+	truncated := truncateName("a_rather_long_project_name")
+	assert.Len(t, truncated, 20)
+	assert.Regexp(t, `^a_rather_lo-[0-9a-f]{8}$`, truncated)
+
+	// Names that only differ after the cut stay unique
+	assert.NotEqual(t, truncated, truncateName("a_rather_long_project_other"))
+}
+
+func TestNameProjectFromLocals(t *testing.T) {
+	require.NoError(t, resetForRun())
+	defer func() {
+		projectNameTmpl, workspaceTmpl, maxNameLength = nil, nil, 0
+	}()
+
+	projectNameTemplate, workspaceTemplate = "{{ .Locals.missing }}", "{{ .Locals.missing }}"
+	require.NoError(t, parseNameTemplates())
+	projectNameTemplate, workspaceTemplate = "", ""
+
+	// Explicit names win over the templates, which are not even rendered
+	project := &AtlantisProject{Dir: "prod/network"}
+	err := nameProject(project, ResolvedLocals{ProjectName: "shared-network", Workspace: "network"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "shared-network", project.Name)
+	assert.Equal(t, "network", project.Workspace)
+
+	// Explicit names are never cut
+	maxNameLength = 10
+	err = nameProject(project, ResolvedLocals{ProjectName: "shared-network", Workspace: "network"}, "")
+	require.Error(t, err)
+	assert.Equal(t, `atlantis_project_name "shared-network" of prod/network is longer than --max-name-length 10`, err.Error())
+}
+
+func TestNameProjectWithEnv(t *testing.T) {
+	require.NoError(t, resetForRun())
+	defer func() {
+		projectNameTmpl, workspaceTmpl = nil, nil
+	}()
+
+	// Without templates, the environment is part of the name, and is the workspace
+	project := &AtlantisProject{Dir: "prod/network", env: "eu"}
+	require.NoError(t, nameProject(project, ResolvedLocals{}, ""))
+	assert.Equal(t, "", project.Name)
+	assert.Equal(t, "eu", project.Workspace)
+
+	projectNameTemplate = "{{ .Env }}-{{ index .DirParts 1 }}"
+	require.NoError(t, parseNameTemplates())
+	projectNameTemplate = ""
+
+	require.NoError(t, nameProject(project, ResolvedLocals{}, ""))
+	assert.Equal(t, "eu-network", project.Name)
+}
+
+func TestLocalsForTemplates(t *testing.T) {
+	locals := ResolvedLocals{values: map[string]cty.Value{
+		"region":  cty.StringVal("eu-west-1"),
+		"count":   cty.NumberIntVal(3),
+		"tags":    cty.ObjectVal(map[string]cty.Value{"team": cty.StringVal("platform")}),
+		"pending": cty.UnknownVal(cty.String),
+	}}
+
+	converted := localsForTemplates(locals)
+	assert.Equal(t, map[string]interface{}{
+		"region": "eu-west-1",
+		"count":  float64(3),
+		"tags":   map[string]interface{}{"team": "platform"},
+	}, converted)
+}
+
+func TestMergeResolvedLocalsValues(t *testing.T) {
+	parent := ResolvedLocals{values: map[string]cty.Value{
+		"region": cty.StringVal("eu-west-1"),
+		"env":    cty.StringVal("prod"),
+	}}
+	child := ResolvedLocals{values: map[string]cty.Value{
+		"region": cty.StringVal("us-east-1"),
+	}}
+
+	merged := mergeResolvedLocals(parent, child)
+	assert.Equal(t, cty.StringVal("us-east-1"), merged.values["region"])
+	assert.Equal(t, cty.StringVal("prod"), merged.values["env"])
+
+	// The locals of the parent are shared, so they must not change
+	assert.Equal(t, cty.StringVal("eu-west-1"), parent.values["region"])
+}
+
+func TestCheckUniqueNames(t *testing.T) {
+	assert.NoError(t, checkUniqueNames([]AtlantisProject{
+		{Dir: "a", Name: "a"},
+		{Dir: "b", Name: "b"},
+		{Dir: "c"},
+		{Dir: "d"},
+		{Dir: "e", Name: "e_app"},
+		{Dir: "e", Name: "e_db"},
+	}))
+
+	err := checkUniqueNames([]AtlantisProject{
+		{Dir: "a", Name: "same"},
+		{Dir: "b", Name: "same"},
+		{Dir: "c", Workspace: "prod"},
+		{Dir: "c", Workspace: "prod"},
+		{Dir: "d", Name: "d"},
+		{Dir: "d"},
+	})
+	require.Error(t, err)
+	assert.Equal(t, "project names must be unique:\n  project name \"same\" is used by a, b\n  workspace \"default\" is used by 2 projects in d\n  workspace \"prod\" is used by 2 projects in c", err.Error())
+}
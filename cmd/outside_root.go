@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// What to do with dependencies outside of the repo root, from `--on-outside-dependency`
+var onOutsideDependency string
+
+// Values of the `--on-outside-dependency` flag
+const (
+	onOutsideDependencyWarn  = "warn"
+	onOutsideDependencyError = "error"
+	onOutsideDependencyDrop  = "drop"
+)
+
+// outsideDependency is a dependency of a module on a path outside of the repo root
+type outsideDependency struct {
+	// Module with the dependency, relative to the root
+	module string
+
+	// Path of the dependency, relative to the directory of the module like in `when_modified`
+	reference string
+
+	kind dependencyKind
+}
+
+// outsideDependencies records the dependencies outside of the repo root
+type outsideDependencies struct {
+	mtx          sync.Mutex
+	dependencies map[outsideDependency]bool
+}
+
+// Dependencies outside of the repo root found during the current run
+var outsideDeps = newOutsideDependencies()
+
+func newOutsideDependencies() *outsideDependencies {
+	return &outsideDependencies{dependencies: map[outsideDependency]bool{}}
+}
+
+func (o *outsideDependencies) add(dependency outsideDependency) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.dependencies[dependency] = true
+}
+
+// sorted returns the dependencies ordered by module and reference
+func (o *outsideDependencies) sorted() []outsideDependency {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	sorted := make([]outsideDependency, 0, len(o.dependencies))
+	for dependency := range o.dependencies {
+		sorted = append(sorted, dependency)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].module != sorted[j].module {
+			return sorted[i].module < sorted[j].module
+		}
+		return sorted[i].reference < sorted[j].reference
+	})
+	return sorted
+}
+
+// outsideDependencyError is returned for a dependency outside of the repo root with
+// `--on-outside-dependency=error`. It is not a parse failure, so `--on-parse-error` doesn't apply to it.
+type outsideDependencyError struct {
+	dependency outsideDependency
+}
+
+func (e outsideDependencyError) Error() string {
+	return fmt.Sprintf("%s depends on %s (%s), which is outside of the repo root", e.dependency.module, e.dependency.reference, e.dependency.kind)
+}
+
+// reportOutsideDependencies logs every dependency outside of the repo root, as Atlantis never sees changes to
+// them. With `--on-outside-dependency=drop`, they are missing from the output.
+func reportOutsideDependencies() {
+	for _, dependency := range outsideDeps.sorted() {
+		if onOutsideDependency == onOutsideDependencyDrop {
+			log.Warnf("Dropped %s (%s) from the dependencies of %s, as it is outside of the repo root", dependency.reference, dependency.kind, dependency.module)
+			continue
+		}
+		log.Warnf("%s depends on %s (%s), which is outside of the repo root, so Atlantis never plans it for changes there", dependency.module, dependency.reference, dependency.kind)
+	}
+}
+
+// checkOutsideDependencies applies `--on-outside-dependency` to the direct dependencies of the module at `path`.
+// Dropped dependencies are not cascaded into either, so files outside of the repo root are not parsed.
+func checkOutsideDependencies(path string, edges []dependencyEdge) ([]dependencyEdge, error) {
+	kept := make([]dependencyEdge, 0, len(edges))
+	for _, edge := range edges {
+		if isWithin(filepath.FromSlash(edge.path), gitRoot) {
+			kept = append(kept, edge)
+			continue
+		}
+
+		reference := edge.path
+		if relative, err := filepath.Rel(filepath.Dir(path), filepath.FromSlash(edge.path)); err == nil {
+			reference = filepath.ToSlash(relative)
+		}
+		dependency := outsideDependency{module: relativeToRoot(path), reference: reference, kind: edge.kind}
+
+		switch onOutsideDependency {
+		case onOutsideDependencyError:
+			return nil, outsideDependencyError{dependency}
+		case onOutsideDependencyDrop:
+			outsideDeps.add(dependency)
+		default:
+			outsideDeps.add(dependency)
+			kept = append(kept, edge)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOutsideDependencies(t *testing.T) {
+	defer func(root string, policy string) { gitRoot, onOutsideDependency = root, policy }(gitRoot, onOutsideDependency)
+	gitRoot = "/repo/"
+
+	edges := []dependencyEdge{
+		{"/repo/vpc/terragrunt.hcl", dependencyKindDependency},
+		{"/other-repo/modules/app/*.tf*", dependencyKindSource},
+		{"/repo-other/common.tfvars", dependencyKindVarFile},
+	}
+
+	onOutsideDependency = onOutsideDependencyWarn
+	outsideDeps = newOutsideDependencies()
+	kept, err := checkOutsideDependencies("/repo/live/app/terragrunt.hcl", edges)
+	require.NoError(t, err)
+	assert.Equal(t, edges, kept)
+	assert.Equal(t, []outsideDependency{
+		{module: "live/app/terragrunt.hcl", reference: "../../../other-repo/modules/app/*.tf*", kind: dependencyKindSource},
+		{module: "live/app/terragrunt.hcl", reference: "../../../repo-other/common.tfvars", kind: dependencyKindVarFile},
+	}, outsideDeps.sorted())
+
+	onOutsideDependency = onOutsideDependencyDrop
+	kept, err = checkOutsideDependencies("/repo/live/app/terragrunt.hcl", edges)
+	require.NoError(t, err)
+	assert.Equal(t, edges[:1], kept)
+
+	onOutsideDependency = onOutsideDependencyError
+	_, err = checkOutsideDependencies("/repo/live/app/terragrunt.hcl", edges)
+	assert.EqualError(t, err, "live/app/terragrunt.hcl depends on ../../../other-repo/modules/app/*.tf* (source), which is outside of the repo root")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Configs classified as parents by classifyParents for the current run, by their cleaned path
+var parentConfigs = map[string]bool{}
+
+// classifyParents classifies the discovered configs at `paths` as parents or modules. Parents are the configs
+// that another discovered config includes, unless `atlantis_parent` in the config itself says otherwise. The
+// direct dependencies of all `paths` have to be computed by `moduleGraph.expand` first.
+func classifyParents(paths []string) map[string]bool {
+	includedBy := map[string][]string{}
+	for _, path := range paths {
+		output, _ := getDependenciesCache.get(path)
+		for _, include := range output.includes {
+			includedBy[include] = append(includedBy[include], relativeToRoot(path))
+		}
+	}
+
+	sorted := uniqueStrings(append([]string{}, paths...))
+	sort.Strings(sorted)
+
+	parents := map[string]bool{}
+	for _, path := range sorted {
+		key := filepath.Clean(path)
+		output, _ := getDependenciesCache.get(path)
+		includers := uniqueStrings(includedBy[key])
+		sort.Strings(includers)
+
+		switch {
+		case output.parentOverride != nil && *output.parentOverride:
+			parents[key] = true
+			log.Debugf("Classified %s as a parent, as set by atlantis_parent", relativeToRoot(path))
+		case output.parentOverride != nil:
+			log.Debugf("Classified %s as a module, as set by atlantis_parent", relativeToRoot(path))
+		case len(includers) > 0:
+			parents[key] = true
+			log.Debugf("Classified %s as a parent, as it is included by %s", relativeToRoot(path), strings.Join(includers, ", "))
+		default:
+			log.Debugf("Classified %s as a module, as no other config includes it", relativeToRoot(path))
+		}
+	}
+
+	return parents
+}
+
+// isParentConfig returns whether the config at `path` was classified as a parent
+func isParentConfig(path string) bool {
+	return parentConfigs[filepath.Clean(path)]
+}
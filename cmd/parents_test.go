@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyParents(t *testing.T) {
+	setupTestGraph(t, nil)
+	parent, module := true, false
+	getDependenciesCache.set("/repo/root.hcl", getDependenciesOutput{})
+	getDependenciesCache.set("/repo/app/terragrunt.hcl", getDependenciesOutput{includes: []string{"/repo/root.hcl"}})
+	getDependenciesCache.set("/repo/standalone/terragrunt.hcl", getDependenciesOutput{})
+	getDependenciesCache.set("/repo/common/terragrunt.hcl", getDependenciesOutput{parentOverride: &parent})
+	getDependenciesCache.set("/repo/network/terragrunt.hcl", getDependenciesOutput{parentOverride: &module})
+	getDependenciesCache.set("/repo/network/subnet/terragrunt.hcl", getDependenciesOutput{includes: []string{"/repo/network/terragrunt.hcl"}})
+
+	parents := classifyParents([]string{
+		"/repo/root.hcl",
+		"/repo/app/terragrunt.hcl",
+		"/repo/standalone/terragrunt.hcl",
+		"/repo/common/terragrunt.hcl",
+		"/repo/network/terragrunt.hcl",
+		"/repo/network/subnet/terragrunt.hcl",
+	})
+
+	assert.Equal(t, map[string]bool{
+		"/repo/root.hcl":              true,
+		"/repo/common/terragrunt.hcl": true,
+	}, parents)
+}
@@ -208,13 +208,12 @@ func extractIncludeConfigs(
 	return tgInc.Include, nil
 }
 
-// Not all modules need an include statement, as they could define everything in one file without a parent
-// The key signifiers of a parent are:
-//   - no include statement
-//   - no terraform source defined
+// parseModule returns the configs that the terragrunt config at `path` includes. Whether a config is a parent
+// is decided by classifyParents, from the includes of all discovered configs.
 //
-// If both of those are true, it is likely a parent module
-func parseModule(ctx *TerragruntParsingContext, path string) (isParent bool, includes []config.IncludeConfig, err error) {
+// Configs that neither include another config nor define a terraform source are `sourceless`. Most of them are
+// only read by other configs, like an env.hcl, so their dependencies are not cascaded into.
+func parseModule(ctx *TerragruntParsingContext, path string) (sourceless bool, includes []config.IncludeConfig, err error) {
 	file, err := parseHclWithCache(path)
 	if err != nil {
 		return false, nil, err
@@ -224,8 +223,6 @@ func parseModule(ctx *TerragruntParsingContext, path string) (isParent bool, inc
 	if err != nil {
 		return false, nil, err
 	}
-
-	// If the file has any `include` blocks it is not a parent
 	if len(terragruntIncludeList) > 0 {
 		return false, terragruntIncludeList, nil
 	}
@@ -239,10 +236,5 @@ func parseModule(ctx *TerragruntParsingContext, path string) (isParent bool, inc
 		logger.Debugf("Failed to fully parse %s: %v", path, err)
 	}
 
-	// If the file does not define a terraform source block, it is likely a parent (though not guaranteed)
-	if parsed.Terraform == nil || parsed.Terraform.Source == nil {
-		return true, nil, nil
-	}
-
-	return false, nil, nil
+	return parsed.Terraform == nil || parsed.Terraform.Source == nil, nil, nil
 }
@@ -389,7 +389,7 @@ func TestParseModule(t *testing.T) {
 	tests := []struct {
 		name                 string
 		content              string
-		expectedIsParent     bool
+		expectedSourceless   bool
 		expectedIncludeCount int
 		shouldError          bool
 	}{
@@ -404,7 +404,6 @@ terraform {
   source = "git::git@github.com:example/repo"
 }
 `,
-			expectedIsParent:     false,
 			expectedIncludeCount: 1,
 			shouldError:          false,
 		},
@@ -417,7 +416,7 @@ locals {
   }
 }
 `,
-			expectedIsParent:     true,
+			expectedSourceless:   true,
 			expectedIncludeCount: 0,
 			shouldError:          false,
 		},
@@ -428,7 +427,6 @@ terraform {
   source = "git::git@github.com:example/repo"
 }
 `,
-			expectedIsParent:     false,
 			expectedIncludeCount: 0,
 			shouldError:          false,
 		},
@@ -448,7 +446,7 @@ locals {
   }
 }
 `,
-			expectedIsParent:     true,
+			expectedSourceless:   true,
 			expectedIncludeCount: 0,
 			shouldError:          false,
 		},
@@ -467,7 +465,6 @@ terraform {
   source = "git::git@github.com:example/repo"
 }
 `,
-			expectedIsParent:     false,
 			expectedIncludeCount: 2,
 			shouldError:          false,
 		},
@@ -489,7 +486,7 @@ terraform {
 			require.NoError(t, err)
 
 			// Parse the module
-			isParent, includes, err := parseModule(ctx, testFile)
+			sourceless, includes, err := parseModule(ctx, testFile)
 
 			if tt.shouldError {
 				assert.Error(t, err)
@@ -497,7 +494,7 @@ terraform {
 			}
 
 			require.NoError(t, err)
-			assert.Equal(t, tt.expectedIsParent, isParent)
+			assert.Equal(t, tt.expectedSourceless, sourceless)
 			assert.Equal(t, tt.expectedIncludeCount, len(includes))
 		})
 	}
@@ -508,25 +505,22 @@ func TestParseModuleWithRealFiles(t *testing.T) {
 	tests := []struct {
 		name                 string
 		examplePath          string
-		expectedIsParent     bool
+		expectedSourceless   bool
 		expectedIncludeCount int
 	}{
 		{
 			name:                 "basic module (child)",
 			examplePath:          "basic_module/terragrunt.hcl",
-			expectedIsParent:     false,
 			expectedIncludeCount: 0,
 		},
 		{
 			name:                 "with parent child",
 			examplePath:          "with_parent/child/terragrunt.hcl",
-			expectedIsParent:     false,
 			expectedIncludeCount: 1,
 		},
 		{
 			name:                 "multiple includes",
 			examplePath:          "multiple_includes/includes_tf_12_then_13/terragrunt.hcl",
-			expectedIsParent:     false,
 			expectedIncludeCount: 2,
 		},
 	}
@@ -547,10 +541,10 @@ func TestParseModuleWithRealFiles(t *testing.T) {
 			require.NoError(t, err)
 
 			// Parse the module
-			isParent, includes, err := parseModule(ctx, testFilePath)
+			sourceless, includes, err := parseModule(ctx, testFilePath)
 			require.NoError(t, err)
 
-			assert.Equal(t, tt.expectedIsParent, isParent)
+			assert.Equal(t, tt.expectedSourceless, sourceless)
 			assert.Equal(t, tt.expectedIncludeCount, len(includes))
 		})
 	}
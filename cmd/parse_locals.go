@@ -5,14 +5,19 @@ package cmd
 // parses the `locals` blocks and evaluates their contents.
 
 import (
+	stderrors "errors"
 	"fmt"
+	"math/big"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/gruntwork-io/go-commons/errors"
 	deprecatedConfig "github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
 	"github.com/hashicorp/hcl/v2"
+	log "github.com/sirupsen/logrus"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -43,8 +48,31 @@ type ResolvedLocals struct {
 	// Terraform version to use just for this project
 	TerraformVersion string
 
+	// Number of levels the dependencies of this module cascade into, overriding `--cascade-depth`.
+	// Negative values cascade all the way down.
+	CascadeDepth *int
+
+	// Name of the project, overriding the generated one
+	ProjectName string
+
+	// Workspace of the project, overriding the generated one
+	Workspace string
+
+	// If set, the module is planned in each of these workspaces, with one project per workspace
+	Workspaces []projectWorkspace
+
 	// If set to true, create Atlantis project
 	markedProject *bool
+
+	// If set, whether the config is a parent, overriding the classification from the includes. Only applies to
+	// the config that sets it, not to its children.
+	parent *bool
+
+	// The configs of `atlantis_inherit_from`, decoded by `resolveLocals` after all other settings
+	inheritFrom cty.Value
+
+	// All evaluated locals of the module and its includes, for the name templates
+	values map[string]cty.Value
 }
 
 // parseHcl uses the HCL2 parser to parse the given string into an HCL file body.
@@ -92,19 +120,87 @@ func mergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLo
 		parent.Skip = child.Skip
 	}
 
+	if child.CascadeDepth != nil {
+		parent.CascadeDepth = child.CascadeDepth
+	}
+
+	if child.ProjectName != "" {
+		parent.ProjectName = child.ProjectName
+	}
+
+	if child.Workspace != "" {
+		parent.Workspace = child.Workspace
+	}
+
+	if child.Workspaces != nil {
+		parent.Workspaces = child.Workspaces
+	}
+
 	if child.markedProject != nil {
 		parent.markedProject = child.markedProject
 	}
 
+	if child.parent != nil {
+		parent.parent = child.parent
+	}
+
 	if child.ApplyRequirements != nil || len(child.ApplyRequirements) > 0 {
 		parent.ApplyRequirements = child.ApplyRequirements
 	}
 
 	parent.ExtraAtlantisDependencies = append(parent.ExtraAtlantisDependencies, child.ExtraAtlantisDependencies...)
 
+	if len(child.values) > 0 {
+		// Copied, as the locals of parents are shared by all of their children
+		values := make(map[string]cty.Value, len(parent.values)+len(child.values))
+		for name, value := range parent.values {
+			values[name] = value
+		}
+		for name, value := range child.values {
+			values[name] = value
+		}
+		parent.values = values
+	}
+
 	return parent
 }
 
+// Merges in values from a child into a parent set of `local` values like `mergeResolvedLocals`, except that
+// lists are combined like the `deep` merge strategy of terragrunt does. Apply requirements of both are kept, and
+// workspaces of the child are added to those of the parent, replacing the ones with the same name.
+func deepMergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLocals {
+	merged := mergeResolvedLocals(parent, child)
+
+	if parent.ApplyRequirements != nil && child.ApplyRequirements != nil {
+		merged.ApplyRequirements = []string{}
+		seen := map[string]bool{}
+		for _, requirement := range append(append([]string{}, parent.ApplyRequirements...), child.ApplyRequirements...) {
+			if !seen[requirement] {
+				seen[requirement] = true
+				merged.ApplyRequirements = append(merged.ApplyRequirements, requirement)
+			}
+		}
+	}
+
+	if parent.Workspaces != nil && child.Workspaces != nil {
+		merged.Workspaces = append([]projectWorkspace{}, parent.Workspaces...)
+		for _, workspace := range child.Workspaces {
+			replaced := false
+			for i := range merged.Workspaces {
+				if merged.Workspaces[i].name == workspace.name {
+					merged.Workspaces[i] = workspace
+					replaced = true
+				}
+			}
+			if !replaced {
+				merged.Workspaces = append(merged.Workspaces, workspace)
+			}
+		}
+	}
+
+	return merged
+}
+
 // Parses a given file, returning a map of all it's `local` values
 func parseLocals(ctx *TerragruntParsingContext, path string, includeFromChild *deprecatedConfig.IncludeConfig) (ResolvedLocals, error) {
 	if !filepath.IsAbs(path) {
@@ -116,84 +212,588 @@ func parseLocals(ctx *TerragruntParsingContext, path string, includeFromChild *d
 		return ResolvedLocals{}, err
 	}
 
-	// Recurse on the parent to merge in the locals from that file
-	mergedParentLocals := ResolvedLocals{}
+	warnUnknownLocals(path, *baseBlocks.Locals)
+	resolved, err := resolveLocals(*baseBlocks.Locals)
+	if err != nil {
+		return ResolvedLocals{}, fmt.Errorf("invalid locals in %s: %w", path, err)
+	}
+	if baseBlocks.Locals.Type().IsObjectType() && baseBlocks.Locals.IsKnown() && !baseBlocks.Locals.IsNull() {
+		resolved.values = baseBlocks.Locals.AsValueMap()
+	}
+
+	// Recurse on the parents to merge in the locals from those files. Like terragrunt, the includes are merged
+	// bottom up with their `merge_strategy`, so that later ones take precedence, and there is only one level of
+	// includes, as terragrunt rejects nested ones.
 	if baseBlocks.TrackInclude != nil && includeFromChild == nil {
-		for _, includeConfig := range baseBlocks.TrackInclude.CurrentList {
-			parentLocals, _ := parseLocals(ctx, includeConfig.Path, &includeConfig)
-			mergedParentLocals = mergeResolvedLocals(mergedParentLocals, parentLocals)
+		includes := baseBlocks.TrackInclude.CurrentList
+		for i := len(includes) - 1; i >= 0; i-- {
+			includeConfig := includes[i]
+			strategy, err := includeConfig.GetMergeStrategy()
+			if err != nil {
+				return ResolvedLocals{}, fmt.Errorf("invalid include in %s: %w", path, err)
+			}
+			// Settings of such parents can still be used explicitly through an exposed include, such as
+			// `include.root.locals.atlantis_workflow`
+			if strategy == deprecatedConfig.NoMerge {
+				continue
+			}
+
+			parentLocals, err := parseLocals(ctx, includeConfig.Path, &includeConfig)
+
+			// Parents that can't be evaluated on their own are fine, but locals with invalid values are not
+			var invalidLocal invalidLocalError
+			if stderrors.As(err, &invalidLocal) {
+				return ResolvedLocals{}, err
+			}
+
+			// Whether a config is a parent is not inherited by its children
+			parentLocals.parent = nil
+
+			if strategy == deprecatedConfig.DeepMerge {
+				resolved = deepMergeResolvedLocals(parentLocals, resolved)
+			} else {
+				resolved = mergeResolvedLocals(parentLocals, resolved)
+			}
 		}
 	}
-	childLocals, err := resolveLocals(*baseBlocks.Locals)
-	if err != nil {
-		return ResolvedLocals{}, err
-	}
-	return mergeResolvedLocals(mergedParentLocals, childLocals), nil
+
+	return resolved, nil
+}
+
+// atlantisLocal is a setting that can be given both as a flat local, and as a key of the `atlantis` object local
+type atlantisLocal struct {
+	// Name of the flat local, such as `atlantis_workflow`
+	flatName string
+
+	// Key in the `atlantis` object local, such as `workflow`
+	objectKey string
+
+	// Decodes a non-null value into `resolved`. `name` is the name of the local for error messages.
+	decode func(name string, value cty.Value, resolved *ResolvedLocals) error
+}
+
+// All settings read from locals, in the order they are decoded
+var atlantisLocals = []atlantisLocal{
+	{"atlantis_workflow", "workflow", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		workflow, err := decodeStringLocal(name, value)
+		resolved.AtlantisWorkflow = workflow
+		return err
+	}},
+	{"atlantis_terraform_version", "terraform_version", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		version, err := decodeStringLocal(name, value)
+		resolved.TerraformVersion = version
+		return err
+	}},
+	{"atlantis_autoplan", "autoplan", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		autoPlan, err := decodeBoolLocal(name, value)
+		resolved.AutoPlan = autoPlan
+		return err
+	}},
+	{"atlantis_skip", "skip", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		skip, err := decodeBoolLocal(name, value)
+		resolved.Skip = skip
+		return err
+	}},
+	{"atlantis_apply_requirements", "apply_requirements", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		requirements, err := decodeStringListLocal(name, value)
+		if err != nil {
+			return err
+		}
+		for _, requirement := range requirements {
+			if !isValidApplyRequirement(requirement) {
+				return invalidLocalError{name, fmt.Sprintf("contains unknown requirement %q, must be one of: %s", requirement, strings.Join(validApplyRequirements, ", "))}
+			}
+		}
+		resolved.ApplyRequirements = requirements
+		return nil
+	}},
+	{"atlantis_cascade", "cascade", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		known, err := checkLocal(name, value)
+		if err != nil || !known {
+			return err
+		}
+		depth, err := parseCascadeDepth(name, value)
+		if err != nil {
+			return err
+		}
+		resolved.CascadeDepth = &depth
+		return nil
+	}},
+	{"atlantis_project_name", "project_name", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		projectName, err := decodeNameLocal(name, value)
+		resolved.ProjectName = projectName
+		return err
+	}},
+	{"atlantis_workspace", "workspace", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		workspace, err := decodeNameLocal(name, value)
+		resolved.Workspace = workspace
+		return err
+	}},
+	{"atlantis_workspaces", "workspaces", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		workspaces, err := decodeWorkspacesLocal(name, value)
+		resolved.Workspaces = workspaces
+		return err
+	}},
+	{"atlantis_project", "project", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		marked, err := decodeBoolLocal(name, value)
+		resolved.markedProject = marked
+		return err
+	}},
+	{"atlantis_parent", "parent", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		parent, err := decodeBoolLocal(name, value)
+		resolved.parent = parent
+		return err
+	}},
+	{"atlantis_inherit_from", "inherit_from", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		resolved.inheritFrom = value
+		return nil
+	}},
+	{"extra_atlantis_dependencies", "extra_dependencies", func(name string, value cty.Value, resolved *ResolvedLocals) error {
+		// Dependencies before an invalid value are kept in the partial result
+		extraDependencies, err := decodeStringListLocal(name, value)
+		for _, extraDependency := range extraDependencies {
+			resolved.ExtraAtlantisDependencies = append(resolved.ExtraAtlantisDependencies, filepath.ToSlash(extraDependency))
+		}
+		return err
+	}},
 }
 
+// Name of the local holding all settings as one object
+const atlantisObjectLocal = "atlantis"
+
 func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 	resolved := ResolvedLocals{}
 
 	// Return an empty set of locals if no `locals` block was present
-	if localsAsCty == cty.NilVal {
+	if localsAsCty == cty.NilVal || localsAsCty.IsNull() {
 		return resolved, nil
 	}
 	rawLocals := localsAsCty.AsValueMap()
 
-	workflowValue, ok := rawLocals["atlantis_workflow"]
-	if ok {
-		resolved.AtlantisWorkflow = workflowValue.AsString()
+	// Object keys of the settings given as flat locals
+	flatKeys := map[string]string{}
+	for _, local := range atlantisLocals {
+		value, ok := rawLocals[local.flatName]
+		if !ok || value.IsNull() {
+			continue
+		}
+		if err := local.decode(local.flatName, value, &resolved); err != nil {
+			return resolved, err
+		}
+		flatKeys[local.objectKey] = local.flatName
 	}
 
-	versionValue, ok := rawLocals["atlantis_terraform_version"]
+	objectValue, ok := rawLocals[atlantisObjectLocal]
 	if ok {
-		resolved.TerraformVersion = versionValue.AsString()
+		if err := decodeAtlantisObject(objectValue, flatKeys, &resolved); err != nil {
+			return resolved, err
+		}
 	}
 
-	autoPlanValue, ok := rawLocals["atlantis_autoplan"]
-	if ok {
-		hasValue := autoPlanValue.True()
-		resolved.AutoPlan = &hasValue
+	// Settings of the module take precedence over the inherited ones
+	if resolved.inheritFrom != cty.NilVal {
+		inherited, err := decodeInheritFromLocal("atlantis_inherit_from", resolved.inheritFrom)
+		if err != nil {
+			return resolved, err
+		}
+		resolved.inheritFrom = cty.NilVal
+		resolved = mergeResolvedLocals(inherited, resolved)
 	}
 
-	skipValue, ok := rawLocals["atlantis_skip"]
-	if ok {
-		hasValue := skipValue.True()
-		resolved.Skip = &hasValue
+	if resolved.Workspace != "" && resolved.Workspaces != nil {
+		return resolved, invalidLocalError{"atlantis_workspaces", "can't be used together with atlantis_workspace"}
 	}
 
-	applyReqs, ok := rawLocals["atlantis_apply_requirements"]
-	if ok {
-		resolved.ApplyRequirements = []string{}
-		it := applyReqs.ElementIterator()
-		for it.Next() {
-			_, val := it.Element()
-			resolved.ApplyRequirements = append(resolved.ApplyRequirements, val.AsString())
+	return resolved, nil
+}
+
+// decodeAtlantisObject decodes the `atlantis` object local into `resolved`. Settings can't be given both in
+// the object and as one of the flat locals in `flatKeys`.
+func decodeAtlantisObject(value cty.Value, flatKeys map[string]string, resolved *ResolvedLocals) error {
+	known, err := checkLocal(atlantisObjectLocal, value)
+	if err != nil || !known {
+		return err
+	}
+	if !value.Type().IsObjectType() && !value.Type().IsMapType() {
+		return invalidLocalError{atlantisObjectLocal, "must be an object, got " + value.Type().FriendlyName()}
+	}
+
+	objectKeys := make([]string, 0, len(atlantisLocals))
+	for _, local := range atlantisLocals {
+		objectKeys = append(objectKeys, local.objectKey)
+	}
+
+	attributes := value.AsValueMap()
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		attribute := attributes[key]
+		name := atlantisObjectLocal + "." + key
+
+		var local *atlantisLocal
+		for i := range atlantisLocals {
+			if atlantisLocals[i].objectKey == key {
+				local = &atlantisLocals[i]
+			}
+		}
+		if local == nil {
+			return invalidLocalError{atlantisObjectLocal, "has unknown key " + describeUnknownKey(key, objectKeys)}
+		}
+
+		if attribute.IsNull() {
+			continue
+		}
+		if flatName, ok := flatKeys[key]; ok {
+			return invalidLocalError{name, "is also set by the local " + flatName + ", only one of them can be used"}
+		}
+
+		// Autoplan settings are nested like in the Atlantis config
+		if key == "autoplan" {
+			attribute, err = decodeAutoplanObject(name, attribute)
+			if err != nil {
+				return err
+			}
+			name += ".enabled"
+			if attribute.IsNull() {
+				continue
+			}
+		}
+
+		if err := local.decode(name, attribute, resolved); err != nil {
+			return err
 		}
 	}
 
-	markedProject, ok := rawLocals["atlantis_project"]
-	if ok {
-		hasValue := markedProject.True()
-		resolved.markedProject = &hasValue
+	return nil
+}
+
+// projectWorkspace is one of the workspaces of `atlantis_workspaces`
+type projectWorkspace struct {
+	name string
+
+	// Settings of just this workspace, overriding those of the module
+	overrides ResolvedLocals
+}
+
+// Keys of the objects in `atlantis_workspaces` that override a setting of the module, next to `name`
+var workspaceOverrideKeys = []string{"workflow", "terraform_version", "autoplan", "apply_requirements", "project_name"}
+
+// The atlantisLocals decoding the workspaceOverrideKeys. Filled in init, as the workspaces are one of the
+// atlantisLocals themselves.
+var workspaceOverrideLocals = map[string]atlantisLocal{}
+
+func init() {
+	for _, local := range atlantisLocals {
+		for _, key := range workspaceOverrideKeys {
+			if local.objectKey == key {
+				workspaceOverrideLocals[key] = local
+			}
+		}
 	}
+}
 
-	extraDependenciesAsCty, ok := rawLocals["extra_atlantis_dependencies"]
-	if ok {
-		it := extraDependenciesAsCty.ElementIterator()
-		for it.Next() {
-			pos, val := it.Element()
-			if !val.Type().Equals(cty.String) {
-				posInt, _ := pos.AsBigFloat().Int64()
-				return resolved, fmt.Errorf("extra_atlantis_dependencies contains non-string value at position %d", posInt)
+// decodeWorkspacesLocal decodes a list of workspace names, or of objects with a `name` and overrides
+func decodeWorkspacesLocal(name string, value cty.Value) ([]projectWorkspace, error) {
+	known, err := checkLocal(name, value)
+	if err != nil || !known {
+		return nil, err
+	}
+	valueType := value.Type()
+	if !valueType.IsListType() && !valueType.IsSetType() && !valueType.IsTupleType() {
+		return nil, invalidLocalError{name, "must be a list of workspace names or objects, got " + valueType.FriendlyName()}
+	}
+
+	validKeys := append([]string{"name"}, workspaceOverrideKeys...)
+	workspaces := []projectWorkspace{}
+	seen := map[string]bool{}
+	position := 0
+	for it := value.ElementIterator(); it.Next(); position++ {
+		_, element := it.Element()
+		elementName := fmt.Sprintf("%s[%d]", name, position)
+
+		workspace := projectWorkspace{}
+		switch {
+		case element.IsNull():
+			return nil, invalidLocalError{elementName, "must be a workspace name or object, got null"}
+		case element.Type().Equals(cty.String):
+			workspace.name = element.AsString()
+		case element.Type().IsObjectType() || element.Type().IsMapType():
+			attributes := element.AsValueMap()
+			keys := make([]string, 0, len(attributes))
+			for key := range attributes {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				attribute := attributes[key]
+				if key == "name" {
+					workspace.name, err = decodeNameLocal(elementName+".name", attribute)
+					if err != nil {
+						return nil, err
+					}
+					continue
+				}
+				local, ok := workspaceOverrideLocals[key]
+				if !ok {
+					return nil, invalidLocalError{elementName, "has unknown key " + describeUnknownKey(key, validKeys)}
+				}
+				if attribute.IsNull() {
+					continue
+				}
+				if err := local.decode(elementName+"."+key, attribute, &workspace.overrides); err != nil {
+					return nil, err
+				}
 			}
+		default:
+			return nil, invalidLocalError{elementName, "must be a workspace name or object, got " + element.Type().FriendlyName()}
+		}
 
-			resolved.ExtraAtlantisDependencies = append(
-				resolved.ExtraAtlantisDependencies,
-				filepath.ToSlash(val.AsString()),
-			)
+		if strings.TrimSpace(workspace.name) == "" {
+			return nil, invalidLocalError{elementName, "must have a non-empty name"}
+		}
+		if seen[workspace.name] {
+			return nil, invalidLocalError{name, fmt.Sprintf("contains the workspace %q more than once", workspace.name)}
 		}
+		seen[workspace.name] = true
+		workspaces = append(workspaces, workspace)
 	}
 
-	return resolved, nil
+	if len(workspaces) == 0 {
+		return nil, invalidLocalError{name, "must contain at least one workspace"}
+	}
+	return workspaces, nil
+}
+
+// decodeAutoplanObject checks the `autoplan` key of the `atlantis` object local, returning its `enabled` value
+func decodeAutoplanObject(name string, value cty.Value) (cty.Value, error) {
+	known, err := checkLocal(name, value)
+	if err != nil || !known {
+		return cty.NullVal(cty.Bool), err
+	}
+	if !value.Type().IsObjectType() && !value.Type().IsMapType() {
+		return cty.NilVal, invalidLocalError{name, "must be an object, got " + value.Type().FriendlyName()}
+	}
+
+	attributes := value.AsValueMap()
+	for key := range attributes {
+		if key != "enabled" {
+			return cty.NilVal, invalidLocalError{name, "has unknown key " + describeUnknownKey(key, []string{"enabled"})}
+		}
+	}
+
+	enabled, ok := attributes["enabled"]
+	if !ok {
+		return cty.NullVal(cty.Bool), nil
+	}
+	return enabled, nil
+}
+
+// describeUnknownKey quotes an unknown key, with a suggestion of the closest valid key if there is one
+func describeUnknownKey(key string, validKeys []string) string {
+	if suggestion := closestName(key, validKeys); suggestion != "" {
+		return fmt.Sprintf("%q, did you mean %q?", key, suggestion)
+	}
+	return fmt.Sprintf("%q, must be one of: %s", key, strings.Join(validKeys, ", "))
+}
+
+// Parses the value of the `atlantis_cascade` local named `name`, which is either `none`, `direct`, `all`
+// or the number of levels to cascade into. `none` and `direct` both keep only the direct dependencies, like
+// `--cascade-depth 0`.
+func parseCascadeDepth(name string, value cty.Value) (int, error) {
+	if value.Type().Equals(cty.String) {
+		switch value.AsString() {
+		case "none", "direct":
+			return 0, nil
+		case "all":
+			return unlimitedCascadeDepth, nil
+		}
+		return 0, invalidLocalError{name, fmt.Sprintf("must be one of none, direct, all or a non-negative number, got %q", value.AsString())}
+	}
+
+	if value.Type().Equals(cty.Number) {
+		depth, accuracy := value.AsBigFloat().Int64()
+		if accuracy == big.Exact && depth >= 0 {
+			return int(depth), nil
+		}
+		return 0, invalidLocalError{name, fmt.Sprintf("must be a non-negative whole number, got %s", value.AsBigFloat().String())}
+	}
+
+	return 0, invalidLocalError{name, "must be a string or a number, got " + value.Type().FriendlyName()}
+}
+
+// The locals this tool reads. Other locals mentioning atlantis are likely misspelled.
+var knownAtlantisLocals = func() []string {
+	known := []string{atlantisObjectLocal}
+	for _, local := range atlantisLocals {
+		known = append(known, local.flatName)
+	}
+	return known
+}()
+
+// The apply requirements Atlantis supports
+var validApplyRequirements = []string{"approved", "mergeable", "undiverged"}
+
+func isValidApplyRequirement(requirement string) bool {
+	for _, valid := range validApplyRequirements {
+		if requirement == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidLocalError is returned for a local that this tool reads, but whose value it can't use
+type invalidLocalError struct {
+	name string
+	msg  string
+}
+
+func (e invalidLocalError) Error() string {
+	return e.name + " " + e.msg
+}
+
+// checkLocal returns an error if the value of a local is not known yet, and false if the local is null,
+// which counts as not being set
+func checkLocal(name string, value cty.Value) (bool, error) {
+	if !value.IsWhollyKnown() {
+		return false, invalidLocalError{name, "has a value that is not known while generating the config, so it can't depend on outputs or other values only known when running terragrunt"}
+	}
+	return !value.IsNull(), nil
+}
+
+func decodeStringLocal(name string, value cty.Value) (string, error) {
+	known, err := checkLocal(name, value)
+	if err != nil || !known {
+		return "", err
+	}
+	if !value.Type().Equals(cty.String) {
+		return "", invalidLocalError{name, "must be a string, got " + value.Type().FriendlyName()}
+	}
+	return value.AsString(), nil
+}
+
+// decodeNameLocal decodes a project name or workspace, which can't be empty
+func decodeNameLocal(name string, value cty.Value) (string, error) {
+	decoded, err := decodeStringLocal(name, value)
+	if err == nil && !value.IsNull() && strings.TrimSpace(decoded) == "" {
+		return "", invalidLocalError{name, "must not be empty"}
+	}
+	return decoded, err
+}
+
+func decodeBoolLocal(name string, value cty.Value) (*bool, error) {
+	known, err := checkLocal(name, value)
+	if err != nil || !known {
+		return nil, err
+	}
+	if !value.Type().Equals(cty.Bool) {
+		return nil, invalidLocalError{name, "must be a bool, got " + value.Type().FriendlyName()}
+	}
+	decoded := value.True()
+	return &decoded, nil
+}
+
+// decodeStringListLocal decodes a list, set or tuple of strings. A null local decodes to nil, an empty list
+// to an empty slice.
+func decodeStringListLocal(name string, value cty.Value) ([]string, error) {
+	known, err := checkLocal(name, value)
+	if err != nil || !known {
+		return nil, err
+	}
+	valueType := value.Type()
+	if !valueType.IsListType() && !valueType.IsSetType() && !valueType.IsTupleType() {
+		return nil, invalidLocalError{name, "must be a list of strings, got " + valueType.FriendlyName()}
+	}
+
+	decoded := []string{}
+	position := 0
+	for it := value.ElementIterator(); it.Next(); position++ {
+		_, val := it.Element()
+		if val.IsNull() || !val.Type().Equals(cty.String) {
+			return decoded, invalidLocalError{name, fmt.Sprintf("contains non-string value at position %d", position)}
+		}
+		decoded = append(decoded, val.AsString())
+	}
+	return decoded, nil
+}
+
+// Locals that were already warned about, so that files parsed more than once only warn once
+var warnedLocals sync.Map
+
+// warnUnknownLocals logs a warning for every local that looks like it is meant for this tool, but isn't
+// one of the knownAtlantisLocals
+func warnUnknownLocals(path string, localsAsCty cty.Value) {
+	if localsAsCty == cty.NilVal || localsAsCty.IsNull() || !localsAsCty.Type().IsObjectType() {
+		return
+	}
+
+	for name := range localsAsCty.Type().AttributeTypes() {
+		if !strings.Contains(name, "atlantis") || isKnownAtlantisLocal(name) {
+			continue
+		}
+		if _, warned := warnedLocals.LoadOrStore(path+"\x00"+name, true); warned {
+			continue
+		}
+
+		if suggestion := suggestAtlantisLocal(name); suggestion != "" {
+			log.Warnf("Unknown local %q in %s, did you mean %q?", name, path, suggestion)
+		} else {
+			log.Warnf("Unknown local %q in %s is ignored by terragrunt-atlantis-config", name, path)
+		}
+	}
+}
+
+func isKnownAtlantisLocal(name string) bool {
+	for _, known := range knownAtlantisLocals {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestAtlantisLocal returns the known local closest to `name`, if it is close enough to be a typo
+func suggestAtlantisLocal(name string) string {
+	return closestName(name, knownAtlantisLocals)
+}
+
+// closestName returns the name in `candidates` closest to `name`, if it is close enough to be a typo
+func closestName(name string, candidates []string) string {
+	suggestion := ""
+	bestDistance := len(name)/3 + 1
+	for _, candidate := range candidates {
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			suggestion = candidate
+			bestDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
 }
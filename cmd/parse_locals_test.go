@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zclconf/go-cty/cty"
@@ -55,6 +57,7 @@ locals {
 func TestMergeResolvedLocals(t *testing.T) {
 	boolTrue := true
 	boolFalse := false
+	parentDepth, childDepth := 0, 2
 
 	parent := ResolvedLocals{
 		AtlantisWorkflow:          "parent-workflow",
@@ -63,6 +66,7 @@ func TestMergeResolvedLocals(t *testing.T) {
 		Skip:                      &boolFalse,
 		ApplyRequirements:         []string{"approved"},
 		ExtraAtlantisDependencies: []string{"parent-dep"},
+		CascadeDepth:              &parentDepth,
 		markedProject:             &boolFalse,
 	}
 
@@ -73,6 +77,7 @@ func TestMergeResolvedLocals(t *testing.T) {
 		Skip:                      &boolTrue,
 		ApplyRequirements:         []string{"mergeable"},
 		ExtraAtlantisDependencies: []string{"child-dep"},
+		CascadeDepth:              &childDepth,
 		markedProject:             &boolTrue,
 	}
 
@@ -84,6 +89,7 @@ func TestMergeResolvedLocals(t *testing.T) {
 	assert.Equal(t, &boolFalse, result.AutoPlan)
 	assert.Equal(t, &boolTrue, result.Skip)
 	assert.Equal(t, []string{"mergeable"}, result.ApplyRequirements)
+	assert.Equal(t, &childDepth, result.CascadeDepth)
 	assert.Equal(t, &boolTrue, result.markedProject)
 
 	// ExtraAtlantisDependencies should be appended
@@ -112,6 +118,39 @@ func TestMergeResolvedLocals_EmptyChild(t *testing.T) {
 	assert.Equal(t, []string{"dep"}, result.ExtraAtlantisDependencies)
 }
 
+func TestDeepMergeResolvedLocals(t *testing.T) {
+	parent := ResolvedLocals{
+		AtlantisWorkflow:          "parent",
+		ApplyRequirements:         []string{"approved", "mergeable"},
+		ExtraAtlantisDependencies: []string{"parent-dep"},
+		Workspaces:                []projectWorkspace{{name: "eu"}, {name: "us", overrides: ResolvedLocals{AtlantisWorkflow: "parent-us"}}},
+	}
+	child := ResolvedLocals{
+		ApplyRequirements:         []string{"mergeable", "undiverged"},
+		ExtraAtlantisDependencies: []string{"child-dep"},
+		Workspaces:                []projectWorkspace{{name: "us", overrides: ResolvedLocals{AtlantisWorkflow: "child-us"}}, {name: "ap"}},
+	}
+
+	result := deepMergeResolvedLocals(parent, child)
+	assert.Equal(t, "parent", result.AtlantisWorkflow)
+	assert.Equal(t, []string{"approved", "mergeable", "undiverged"}, result.ApplyRequirements)
+	assert.Equal(t, []string{"parent-dep", "child-dep"}, result.ExtraAtlantisDependencies)
+	assert.Equal(t, []projectWorkspace{
+		{name: "eu"},
+		{name: "us", overrides: ResolvedLocals{AtlantisWorkflow: "child-us"}},
+		{name: "ap"},
+	}, result.Workspaces)
+
+	// The lists of the parent are shared, so they must not change
+	assert.Equal(t, []string{"approved", "mergeable"}, parent.ApplyRequirements)
+	assert.Equal(t, "parent-us", parent.Workspaces[1].overrides.AtlantisWorkflow)
+
+	// Lists set by only one of them are kept as they are
+	result = deepMergeResolvedLocals(parent, ResolvedLocals{})
+	assert.Equal(t, parent.ApplyRequirements, result.ApplyRequirements)
+	assert.Equal(t, parent.Workspaces, result.Workspaces)
+}
+
 func TestResolveLocals(t *testing.T) {
 	t.Run("empty locals", func(t *testing.T) {
 		result, err := resolveLocals(cty.NilVal)
@@ -216,6 +255,37 @@ func TestResolveLocals(t *testing.T) {
 		assert.NotEqual(t, ResolvedLocals{}, result) // Should return partial result
 	})
 
+	t.Run("atlantis_cascade", func(t *testing.T) {
+		cases := []struct {
+			value    cty.Value
+			expected int
+		}{
+			{cty.StringVal("none"), 0},
+			{cty.StringVal("direct"), 0},
+			{cty.StringVal("all"), unlimitedCascadeDepth},
+			{cty.NumberIntVal(3), 3},
+		}
+		for _, c := range cases {
+			result, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{"atlantis_cascade": c.value}))
+			require.NoError(t, err)
+			require.NotNil(t, result.CascadeDepth)
+			assert.Equal(t, c.expected, *result.CascadeDepth)
+		}
+	})
+
+	t.Run("invalid atlantis_cascade", func(t *testing.T) {
+		for _, value := range []cty.Value{
+			cty.StringVal("everything"),
+			cty.NumberIntVal(-1),
+			cty.NumberFloatVal(1.5),
+			cty.BoolVal(true),
+		} {
+			_, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{"atlantis_cascade": value}))
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "atlantis_cascade")
+		}
+	})
+
 	t.Run("all locals combined", func(t *testing.T) {
 		locals := cty.ObjectVal(map[string]cty.Value{
 			"atlantis_workflow":          cty.StringVal("custom"),
@@ -247,6 +317,356 @@ func TestResolveLocals(t *testing.T) {
 	})
 }
 
+func TestResolveInheritFrom(t *testing.T) {
+	readConfig := func(locals map[string]cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{"locals": cty.ObjectVal(locals), "inputs": cty.EmptyObjectVal})
+	}
+	env := readConfig(map[string]cty.Value{
+		"atlantis_workflow":           cty.StringVal("env"),
+		"atlantis_terraform_version":  cty.StringVal("1.5.7"),
+		"extra_atlantis_dependencies": cty.TupleVal([]cty.Value{cty.StringVal("env.yaml")}),
+	})
+	env = withReadConfigPath(env, "/repo/env.hcl")
+
+	// A config with the same locals read from another file doesn't add that file
+	result, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{
+		"atlantis_inherit_from": cty.TupleVal([]cty.Value{
+			env,
+			readConfig(map[string]cty.Value{"atlantis_workflow": cty.StringVal("region"), "region": cty.StringVal("eu")}),
+		}),
+		"other_env":         withReadConfigPath(env, "/repo/other/env.hcl"),
+		"atlantis_autoplan": cty.True,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "region", result.AtlantisWorkflow)
+	assert.Equal(t, "1.5.7", result.TerraformVersion)
+	assert.Equal(t, true, *result.AutoPlan)
+	assert.Equal(t, []string{"/repo/env.hcl", "env.yaml"}, result.ExtraAtlantisDependencies)
+
+	// Settings of the module take precedence
+	result, err = resolveLocals(cty.ObjectVal(map[string]cty.Value{
+		"atlantis": cty.ObjectVal(map[string]cty.Value{
+			"inherit_from": cty.TupleVal([]cty.Value{env}),
+			"workflow":     cty.StringVal("module"),
+		}),
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "module", result.AtlantisWorkflow)
+	assert.Equal(t, "1.5.7", result.TerraformVersion)
+
+	errorCases := []struct {
+		name     string
+		value    cty.Value
+		expected string
+	}{
+		{"not a list", cty.StringVal("env.hcl"), "atlantis_inherit_from must be a list of configs read with read_terragrunt_config, got string"},
+		{"not a config", cty.TupleVal([]cty.Value{env, cty.StringVal("env.hcl")}), "atlantis_inherit_from [1] must be a config read with read_terragrunt_config"},
+		{"invalid inherited local", cty.TupleVal([]cty.Value{readConfig(map[string]cty.Value{"atlantis_autoplan": cty.StringVal("yes")})}), "atlantis_inherit_from[0]: atlantis_autoplan"},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{"atlantis_inherit_from": c.value}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
+
+func TestResolveLocalsValidation(t *testing.T) {
+	t.Run("null locals are unset", func(t *testing.T) {
+		locals := cty.ObjectVal(map[string]cty.Value{
+			"atlantis_workflow":           cty.NullVal(cty.String),
+			"atlantis_autoplan":           cty.NullVal(cty.Bool),
+			"atlantis_skip":               cty.NullVal(cty.DynamicPseudoType),
+			"atlantis_cascade":            cty.NullVal(cty.String),
+			"atlantis_apply_requirements": cty.NullVal(cty.List(cty.String)),
+		})
+
+		result, err := resolveLocals(locals)
+		require.NoError(t, err)
+		assert.Equal(t, ResolvedLocals{}, result)
+	})
+
+	t.Run("empty apply requirements override the parent", func(t *testing.T) {
+		result, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{
+			"atlantis_apply_requirements": cty.ListValEmpty(cty.String),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{}, result.ApplyRequirements)
+	})
+
+	t.Run("all apply requirements supported by Atlantis", func(t *testing.T) {
+		result, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{
+			"atlantis_apply_requirements": cty.SetVal([]cty.Value{
+				cty.StringVal("approved"),
+				cty.StringVal("mergeable"),
+				cty.StringVal("undiverged"),
+			}),
+		}))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"approved", "mergeable", "undiverged"}, result.ApplyRequirements)
+	})
+
+	t.Run("explicit names", func(t *testing.T) {
+		result, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{
+			"atlantis_project_name": cty.StringVal("shared-network"),
+			"atlantis":              cty.ObjectVal(map[string]cty.Value{"workspace": cty.StringVal("network")}),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "shared-network", result.ProjectName)
+		assert.Equal(t, "network", result.Workspace)
+	})
+
+	t.Run("workspaces", func(t *testing.T) {
+		result, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{
+			"atlantis_workflow": cty.StringVal("default"),
+			"atlantis_workspaces": cty.TupleVal([]cty.Value{
+				cty.StringVal("staging"),
+				cty.ObjectVal(map[string]cty.Value{
+					"name":               cty.StringVal("prod"),
+					"workflow":           cty.StringVal("prod"),
+					"apply_requirements": cty.ListVal([]cty.Value{cty.StringVal("approved")}),
+				}),
+			}),
+		}))
+		require.NoError(t, err)
+		require.Len(t, result.Workspaces, 2)
+		assert.Equal(t, projectWorkspace{name: "staging"}, result.Workspaces[0])
+		assert.Equal(t, "prod", result.Workspaces[1].name)
+		assert.Equal(t, ResolvedLocals{AtlantisWorkflow: "prod", ApplyRequirements: []string{"approved"}}, result.Workspaces[1].overrides)
+	})
+
+	t.Run("workspaces with a single workspace", func(t *testing.T) {
+		_, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{
+			"atlantis_workspace":  cty.StringVal("prod"),
+			"atlantis_workspaces": cty.ListVal([]cty.Value{cty.StringVal("prod")}),
+		}))
+		require.Error(t, err)
+		assert.Equal(t, "atlantis_workspaces can't be used together with atlantis_workspace", err.Error())
+	})
+
+	cases := []struct {
+		name     string
+		local    string
+		value    cty.Value
+		expected string
+	}{
+		{"number as workflow", "atlantis_workflow", cty.NumberIntVal(1), "atlantis_workflow must be a string, got number"},
+		{"list as terraform version", "atlantis_terraform_version", cty.ListVal([]cty.Value{cty.StringVal("1.5.0")}), "atlantis_terraform_version must be a string, got list of string"},
+		{"string as autoplan", "atlantis_autoplan", cty.StringVal("true"), "atlantis_autoplan must be a bool, got string"},
+		{"number as skip", "atlantis_skip", cty.NumberIntVal(1), "atlantis_skip must be a bool, got number"},
+		{"string as project marker", "atlantis_project", cty.StringVal("yes"), "atlantis_project must be a bool, got string"},
+		{"empty project name", "atlantis_project_name", cty.StringVal(" "), "atlantis_project_name must not be empty"},
+		{"number as workspace", "atlantis_workspace", cty.NumberIntVal(1), "atlantis_workspace must be a string, got number"},
+		{"string as workspaces", "atlantis_workspaces", cty.StringVal("prod"), "atlantis_workspaces must be a list of workspace names or objects, got string"},
+		{"empty workspaces", "atlantis_workspaces", cty.ListValEmpty(cty.String), "atlantis_workspaces must contain at least one workspace"},
+		{"duplicate workspaces", "atlantis_workspaces", cty.ListVal([]cty.Value{cty.StringVal("prod"), cty.StringVal("prod")}), `atlantis_workspaces contains the workspace "prod" more than once`},
+		{"workspace without name", "atlantis_workspaces", cty.TupleVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{"workflow": cty.StringVal("prod")})}), "atlantis_workspaces[0] must have a non-empty name"},
+		{"unknown workspace key", "atlantis_workspaces", cty.TupleVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("prod"), "worklfow": cty.StringVal("prod")})}), `atlantis_workspaces[0] has unknown key "worklfow", did you mean "workflow"?`},
+		{"invalid workspace override", "atlantis_workspaces", cty.TupleVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("prod"), "autoplan": cty.StringVal("yes")})}), "atlantis_workspaces[0].autoplan must be a bool, got string"},
+		{"string as apply requirements", "atlantis_apply_requirements", cty.StringVal("approved"), "atlantis_apply_requirements must be a list of strings, got string"},
+		{"misspelled apply requirement", "atlantis_apply_requirements", cty.ListVal([]cty.Value{cty.StringVal("aproved")}), `atlantis_apply_requirements contains unknown requirement "aproved", must be one of: approved, mergeable, undiverged`},
+		{"null apply requirement", "atlantis_apply_requirements", cty.ListVal([]cty.Value{cty.NullVal(cty.String)}), "atlantis_apply_requirements contains non-string value at position 0"},
+		{"unknown workflow", "atlantis_workflow", cty.UnknownVal(cty.String), "atlantis_workflow has a value that is not known while generating the config"},
+		{"partially unknown dependencies", "extra_atlantis_dependencies", cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.UnknownVal(cty.String)}), "extra_atlantis_dependencies has a value that is not known"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := resolveLocals(cty.ObjectVal(map[string]cty.Value{c.local: c.value}))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+
+			var invalidLocal invalidLocalError
+			assert.ErrorAs(t, err, &invalidLocal)
+		})
+	}
+}
+
+func TestResolveAtlantisObjectLocal(t *testing.T) {
+	t.Run("all settings", func(t *testing.T) {
+		locals := cty.ObjectVal(map[string]cty.Value{
+			"atlantis": cty.ObjectVal(map[string]cty.Value{
+				"workflow":           cty.StringVal("custom"),
+				"terraform_version":  cty.StringVal("1.5.0"),
+				"autoplan":           cty.ObjectVal(map[string]cty.Value{"enabled": cty.BoolVal(false)}),
+				"skip":               cty.BoolVal(true),
+				"project":            cty.BoolVal(true),
+				"cascade":            cty.StringVal("direct"),
+				"apply_requirements": cty.TupleVal([]cty.Value{cty.StringVal("approved")}),
+				"extra_dependencies": cty.TupleVal([]cty.Value{cty.StringVal("../shared")}),
+			}),
+		})
+
+		result, err := resolveLocals(locals)
+		require.NoError(t, err)
+
+		assert.Equal(t, "custom", result.AtlantisWorkflow)
+		assert.Equal(t, "1.5.0", result.TerraformVersion)
+		require.NotNil(t, result.AutoPlan)
+		assert.False(t, *result.AutoPlan)
+		require.NotNil(t, result.Skip)
+		assert.True(t, *result.Skip)
+		require.NotNil(t, result.markedProject)
+		assert.True(t, *result.markedProject)
+		require.NotNil(t, result.CascadeDepth)
+		assert.Equal(t, 0, *result.CascadeDepth)
+		assert.Equal(t, []string{"approved"}, result.ApplyRequirements)
+		assert.Equal(t, []string{"../shared"}, result.ExtraAtlantisDependencies)
+	})
+
+	t.Run("combined with other flat locals", func(t *testing.T) {
+		locals := cty.ObjectVal(map[string]cty.Value{
+			"atlantis_workflow": cty.StringVal("flat"),
+			"atlantis_skip":     cty.NullVal(cty.Bool),
+			"atlantis": cty.ObjectVal(map[string]cty.Value{
+				"skip":     cty.BoolVal(true),
+				"autoplan": cty.EmptyObjectVal,
+			}),
+		})
+
+		result, err := resolveLocals(locals)
+		require.NoError(t, err)
+		assert.Equal(t, "flat", result.AtlantisWorkflow)
+		require.NotNil(t, result.Skip)
+		assert.True(t, *result.Skip)
+		assert.Nil(t, result.AutoPlan)
+	})
+
+	cases := []struct {
+		name     string
+		locals   map[string]cty.Value
+		expected string
+	}{
+		{
+			"not an object",
+			map[string]cty.Value{"atlantis": cty.StringVal("workflow")},
+			"atlantis must be an object, got string",
+		},
+		{
+			"misspelled key",
+			map[string]cty.Value{"atlantis": cty.ObjectVal(map[string]cty.Value{"worklfow": cty.StringVal("x")})},
+			`atlantis has unknown key "worklfow", did you mean "workflow"?`,
+		},
+		{
+			"unknown key",
+			map[string]cty.Value{"atlantis": cty.ObjectVal(map[string]cty.Value{"environment": cty.StringVal("x")})},
+			`atlantis has unknown key "environment", must be one of: workflow, terraform_version, autoplan`,
+		},
+		{
+			"unknown autoplan key",
+			map[string]cty.Value{"atlantis": cty.ObjectVal(map[string]cty.Value{
+				"autoplan": cty.ObjectVal(map[string]cty.Value{"enable": cty.BoolVal(true)}),
+			})},
+			`atlantis.autoplan has unknown key "enable", did you mean "enabled"?`,
+		},
+		{
+			"autoplan as a bool",
+			map[string]cty.Value{"atlantis": cty.ObjectVal(map[string]cty.Value{"autoplan": cty.BoolVal(true)})},
+			"atlantis.autoplan must be an object, got bool",
+		},
+		{
+			"wrong type",
+			map[string]cty.Value{"atlantis": cty.ObjectVal(map[string]cty.Value{
+				"autoplan": cty.ObjectVal(map[string]cty.Value{"enabled": cty.StringVal("yes")}),
+			})},
+			"atlantis.autoplan.enabled must be a bool, got string",
+		},
+		{
+			"invalid requirement",
+			map[string]cty.Value{"atlantis": cty.ObjectVal(map[string]cty.Value{
+				"apply_requirements": cty.TupleVal([]cty.Value{cty.StringVal("aproved")}),
+			})},
+			`atlantis.apply_requirements contains unknown requirement "aproved"`,
+		},
+		{
+			"set in both forms",
+			map[string]cty.Value{
+				"atlantis_workflow": cty.StringVal("flat"),
+				"atlantis":          cty.ObjectVal(map[string]cty.Value{"workflow": cty.StringVal("object")}),
+			},
+			"atlantis.workflow is also set by the local atlantis_workflow, only one of them can be used",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := resolveLocals(cty.ObjectVal(c.locals))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
+
+func TestParseLocalsInvalidParentLocal(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "root.hcl"), []byte(`
+locals {
+  atlantis_autoplan = "yes"
+}
+`), 0644))
+
+	childDir := filepath.Join(tmpDir, "child")
+	require.NoError(t, os.MkdirAll(childDir, 0755))
+	childPath := filepath.Join(childDir, "terragrunt.hcl")
+	require.NoError(t, os.WriteFile(childPath, []byte(`
+include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+terraform {
+  source = "git::https://github.com/example/module.git"
+}
+`), 0644))
+
+	ctx, err := NewParsingContextWithConfigPath(context.Background(), childPath)
+	require.NoError(t, err)
+
+	_, err = parseLocals(ctx, childPath, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid locals in "+filepath.Join(tmpDir, "root.hcl"))
+	assert.Contains(t, err.Error(), "atlantis_autoplan must be a bool, got string")
+}
+
+func TestWarnUnknownLocals(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	locals := cty.ObjectVal(map[string]cty.Value{
+		"atlantis_worklfow":     cty.StringVal("typo"),
+		"atlantis_environment":  cty.StringVal("prod"),
+		"atlantis_workflow":     cty.StringVal("known"),
+		"unrelated_local_value": cty.StringVal("ignored"),
+	})
+
+	warnUnknownLocals("/warn-unknown-locals/terragrunt.hcl", locals)
+	// Parsing the same file again does not repeat the warnings
+	warnUnknownLocals("/warn-unknown-locals/terragrunt.hcl", locals)
+
+	messages := []string{}
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		messages = append(messages, entry.Message)
+	}
+	assert.ElementsMatch(t, []string{
+		`Unknown local "atlantis_worklfow" in /warn-unknown-locals/terragrunt.hcl, did you mean "atlantis_workflow"?`,
+		`Unknown local "atlantis_environment" in /warn-unknown-locals/terragrunt.hcl is ignored by terragrunt-atlantis-config`,
+	}, messages)
+}
+
+func TestSuggestAtlantisLocal(t *testing.T) {
+	assert.Equal(t, "atlantis_apply_requirements", suggestAtlantisLocal("atlantis_apply_requirement"))
+	assert.Equal(t, "extra_atlantis_dependencies", suggestAtlantisLocal("extra_atlantis_dependecies"))
+	assert.Equal(t, "atlantis_skip", suggestAtlantisLocal("atlantis_skp"))
+	assert.Equal(t, "atlantis", suggestAtlantisLocal("atlantsi"))
+	assert.Equal(t, "", suggestAtlantisLocal("atlantis_environment"))
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("same", "same"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, 1, editDistance("aproved", "approved"))
+	assert.Equal(t, 2, editDistance("worklfow", "workflow"))
+}
+
 func TestParseLocals_Integration(t *testing.T) {
 	// Create a temporary directory structure for testing
 	tmpDir, err := os.MkdirTemp("", "parse-locals-test")
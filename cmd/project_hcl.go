@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Let the order of `--project-hcl-files` decide which nested project hcl dir owns a module, from
+// `--project-hcl-precedence`
+var projectHclPrecedence bool
+
+// List the files of the modules of project hcl projects in their `when_modified` instead of all files below them,
+// from `--precise-hcl-project-when-modified`
+var preciseHclProjectWhenModified bool
+
+// Merge the patterns of `--precise-hcl-project-when-modified` into wildcards, from
+// `--compress-hcl-project-when-modified`
+var compressHclProjectWhenModified bool
+
+// applyProjectHclPrecedence drops the project hcl dirs that are within or at the dir of a project hcl file listed
+// earlier in `--project-hcl-files`, as their modules belong to the project of that file
+func applyProjectHclPrecedence(projectHclDirMap map[string][]string) map[string][]string {
+	kept := map[string][]string{}
+	var earlierDirs []string
+	for _, projectHclFile := range projectHclFiles {
+		for _, dir := range projectHclDirMap[projectHclFile] {
+			owner := innermostDir(dir, earlierDirs)
+			if owner != "" {
+				log.Debugf("Not creating a %s project for %s, as its modules belong to the project of %s", projectHclFile, relativeToRoot(dir), relativeToRoot(owner))
+				continue
+			}
+			kept[projectHclFile] = append(kept[projectHclFile], dir)
+		}
+		earlierDirs = append(earlierDirs, kept[projectHclFile]...)
+	}
+	return kept
+}
+
+// ownedModules returns the modules of `paths` that belong to the project hcl dir `dir`, as it is the innermost one
+// of `projectHclDirs` they are in
+func ownedModules(dir string, paths []string, projectHclDirs []string) []string {
+	owned := []string{}
+	for _, path := range paths {
+		owner := innermostDir(path, projectHclDirs)
+		if owner != dir {
+			log.Debugf("%s belongs to the project of %s rather than %s", relativeToRoot(path), relativeToRoot(owner), relativeToRoot(dir))
+			continue
+		}
+		owned = append(owned, path)
+	}
+	return owned
+}
+
+// innerProjectHclDirs returns the outermost of `projectHclDirs` below `dir`, whose subtrees the project of `dir`
+// excludes
+func innerProjectHclDirs(dir string, projectHclDirs []string) []string {
+	inner := []string{}
+	for _, other := range projectHclDirs {
+		if other != dir && isWithin(other, dir) {
+			inner = append(inner, other)
+		}
+	}
+	return outermostDirs(inner)
+}
+
+// innerModuleConfigs returns the modules of `paths` within `innerDirs`, which belong to the projects of the nested
+// project hcl files rather than the one above them
+func innerModuleConfigs(paths []string, innerDirs []string) map[string]bool {
+	inner := map[string]bool{}
+	for _, path := range paths {
+		if withinAny(path, innerDirs) {
+			inner[filepath.Clean(path)] = true
+		}
+	}
+	return inner
+}
+
+// innermostDir returns the deepest of `dirs` that `path` is within, or "" when there is none
+func innermostDir(path string, dirs []string) string {
+	innermost := ""
+	for _, dir := range dirs {
+		if isWithin(path, dir) && len(dir) > len(innermost) {
+			innermost = dir
+		}
+	}
+	return innermost
+}
+
+// withinAny returns whether `path` is within any of `dirs`
+func withinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithin(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedSubtrees returns the `when_modified` patterns that exclude `innerDirs` from the project of `dir`
+func excludedSubtrees(dir string, innerDirs []string) ([]string, error) {
+	patterns := []string{}
+	for _, innerDir := range innerDirs {
+		relativeDir, err := filepath.Rel(dir, innerDir)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, "!"+filepath.ToSlash(relativeDir)+"/**")
+	}
+	return patterns, nil
+}
+
+// moduleFilePatterns returns the `when_modified` patterns of the own files of the module at `sourcePath`, relative
+// to the project hcl dir `dir`
+func moduleFilePatterns(dir string, sourcePath string) ([]string, error) {
+	moduleDir, err := filepath.Rel(dir, filepath.Dir(sourcePath))
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := []string{}
+	for _, pattern := range []string{"*.hcl", terraformFilePattern, tofuFilePattern} {
+		patterns = append(patterns, filepath.ToSlash(filepath.Join(moduleDir, pattern)))
+	}
+	return patterns, nil
+}
+
+// compressWhenModified merges the patterns below the project hcl dir `dir` that only differ in the name of one
+// directory into a single one with a wildcard, when they cover every subdirectory of its parent. The result
+// matches the same files, as long as no directories are added: the wildcard also matches later ones.
+func compressWhenModified(dir string, patterns []string) []string {
+	for {
+		compressed, changed := compressWhenModifiedOnce(dir, patterns)
+		if !changed {
+			return compressed
+		}
+		patterns = compressed
+	}
+}
+
+func compressWhenModifiedOnce(dir string, patterns []string) ([]string, bool) {
+	type group struct {
+		prefix  string
+		suffix  string
+		names   map[string]bool
+		members []int
+	}
+
+	groups := map[string]*group{}
+	keys := []string{}
+	for i, pattern := range patterns {
+		components := strings.Split(pattern, "/")
+		if len(components) < 2 || strings.HasPrefix(pattern, "!") || strings.Contains(pattern, "**") || components[0] == ".." {
+			continue
+		}
+
+		// The last directory before the wildcards of earlier merges
+		j := len(components) - 2
+		for j >= 0 && components[j] == "*" {
+			j--
+		}
+		if j < 0 || strings.ContainsAny(components[j], "*?[\\") {
+			continue
+		}
+
+		prefix := strings.Join(components[:j], "/")
+		suffix := strings.Join(components[j+1:], "/")
+		key := prefix + "\x00" + suffix
+		if groups[key] == nil {
+			groups[key] = &group{prefix: prefix, suffix: suffix, names: map[string]bool{}}
+			keys = append(keys, key)
+		}
+		groups[key].names[components[j]] = true
+		groups[key].members = append(groups[key].members, i)
+	}
+
+	replaced := map[int]string{}
+	dropped := map[int]bool{}
+	for _, key := range keys {
+		g := groups[key]
+		if len(g.names) < 2 || strings.ContainsAny(g.prefix, "*?[\\") || !coversSubdirectories(filepath.Join(dir, filepath.FromSlash(g.prefix)), g.names) {
+			continue
+		}
+
+		merged := path.Join(g.prefix, "*", g.suffix)
+		replaced[g.members[0]] = merged
+		for _, member := range g.members[1:] {
+			dropped[member] = true
+		}
+	}
+	if len(replaced) == 0 {
+		return patterns, false
+	}
+
+	compressed := []string{}
+	for i, pattern := range patterns {
+		switch {
+		case dropped[i]:
+		case replaced[i] != "":
+			compressed = append(compressed, replaced[i])
+		default:
+			compressed = append(compressed, pattern)
+		}
+	}
+	return uniqueStrings(compressed), true
+}
+
+// coversSubdirectories returns whether `names` are exactly the subdirectories of `dir`
+func coversSubdirectories(dir string, names map[string]bool) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	subdirectories := 0
+	for _, entry := range entries {
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if !names[entry.Name()] {
+			return false
+		}
+		subdirectories++
+	}
+	return subdirectories == len(names)
+}
+
+// dropCoveredPaths drops the paths of `patterns` without wildcards that another one of them already matches
+func dropCoveredPaths(patterns []string) []string {
+	kept := []string{}
+	for _, pattern := range patterns {
+		covered := false
+		if !strings.ContainsAny(pattern, "*?[\\") && !strings.HasPrefix(pattern, "!") {
+			for _, other := range patterns {
+				if matched, err := path.Match(other, pattern); other != pattern && err == nil && matched {
+					covered = true
+					break
+				}
+			}
+		}
+		if !covered {
+			kept = append(kept, pattern)
+		}
+	}
+	return kept
+}
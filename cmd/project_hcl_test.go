@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectHclOwnership(t *testing.T) {
+	region := filepath.Join(string(filepath.Separator), "repo", "region")
+	service := filepath.Join(region, "service")
+	api := filepath.Join(service, "api")
+	dirs := []string{region, service, api}
+
+	vpc := filepath.Join(region, "vpc", "terragrunt.hcl")
+	db := filepath.Join(service, "db", "terragrunt.hcl")
+	handler := filepath.Join(api, "handler", "terragrunt.hcl")
+	modules := []string{vpc, db, handler}
+
+	assert.Equal(t, []string{vpc}, ownedModules(region, modules, dirs))
+	assert.Equal(t, []string{db}, ownedModules(service, modules, dirs))
+	assert.Equal(t, []string{handler}, ownedModules(api, modules, dirs))
+
+	// Only the outermost nested dirs are excluded, which covers the ones below them
+	assert.Equal(t, []string{service}, innerProjectHclDirs(region, dirs))
+	assert.Equal(t, []string{}, innerProjectHclDirs(api, dirs))
+
+	exclusions, err := excludedSubtrees(region, innerProjectHclDirs(region, dirs))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"!service/**"}, exclusions)
+
+	// The modules of nested projects are not dependencies of the project of `region`
+	assert.Equal(t, map[string]bool{db: true, handler: true}, innerModuleConfigs(modules, innerProjectHclDirs(region, dirs)))
+}
+
+func TestApplyProjectHclPrecedence(t *testing.T) {
+	defer func(files []string) { projectHclFiles = files }(projectHclFiles)
+	projectHclFiles = []string{"region.hcl", "stack.hcl"}
+
+	region := filepath.Join(string(filepath.Separator), "repo", "region")
+	other := filepath.Join(string(filepath.Separator), "repo", "other")
+
+	kept := applyProjectHclPrecedence(map[string][]string{
+		"region.hcl": {region},
+		"stack.hcl":  {filepath.Join(region, "service"), region, other},
+	})
+
+	assert.Equal(t, map[string][]string{
+		"region.hcl": {region},
+		"stack.hcl":  {other},
+	}, kept)
+}
+
+func TestCompressWhenModified(t *testing.T) {
+	dir := t.TempDir()
+	for _, subdir := range []string{"eu/app", "eu/db", "us/app", "us/db", "tools/lint"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, subdir), 0755))
+	}
+
+	patterns := []string{
+		"stack.hcl",
+		"eu/app/*.hcl",
+		"eu/db/*.hcl",
+		"us/app/*.hcl",
+		"us/db/*.hcl",
+		"../shared/*.hcl",
+	}
+
+	// The modules of eu and us are merged, but not eu and us themselves, as tools is a subdirectory of dir too
+	assert.Equal(t, []string{"stack.hcl", "eu/*/*.hcl", "us/*/*.hcl", "../shared/*.hcl"}, compressWhenModified(dir, patterns))
+
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "tools")))
+	assert.Equal(t, []string{"stack.hcl", "*/*/*.hcl", "../shared/*.hcl"}, compressWhenModified(dir, patterns))
+
+	// Patterns are only merged when they cover all subdirectories
+	assert.Equal(t, []string{"eu/app/*.hcl", "us/db/*.hcl"}, compressWhenModified(dir, []string{"eu/app/*.hcl", "us/db/*.hcl"}))
+}
+
+func TestCompressWhenModifiedAddedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, subdir := range []string{"app", "db"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, subdir), 0755))
+	}
+	compressed := compressWhenModified(dir, []string{"app/*.hcl", "db/*.hcl"})
+	assert.Equal(t, []string{"*/*.hcl"}, compressed)
+
+	// A directory added after generation is matched by the merged pattern, until it is generated again
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cache"), 0755))
+	matched, err := path.Match(compressed[0], "cache/terragrunt.hcl")
+	require.NoError(t, err)
+	assert.True(t, matched)
+	assert.Equal(t, []string{"app/*.hcl", "db/*.hcl"}, compressWhenModified(dir, []string{"app/*.hcl", "db/*.hcl"}))
+}
+
+func TestDropCoveredPaths(t *testing.T) {
+	assert.Equal(t,
+		[]string{"vpc/*.hcl", "*/*.tf*", "../vpc/terragrunt.hcl"},
+		dropCoveredPaths([]string{"vpc/*.hcl", "vpc/terragrunt.hcl", "*/*.tf*", "db/main.tf", "../vpc/terragrunt.hcl"}),
+	)
+}
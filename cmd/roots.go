@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Root of the git repo that project dirs are relative to, from `--repo-root`
+var repoRootPath string
+
+// Directories to look for modules in, from `--scan-root`
+var scanRootPaths []string
+
+// Absolute directories modules are looked for in, with a trailing slash, set by `resolveRoots`
+var scanRoots []string
+
+// resolveRoots sets the root of the repo and the directories to look for modules in. Without `--scan-root`,
+// modules are looked for in `--root`, and without `--repo-root`, the repo root is the closest parent of the
+// first scan root with a .git directory, or `--root` when there is none or no `--scan-root` is given.
+func resolveRoots() error {
+	root, err := filepath.Abs(gitRoot)
+	if err != nil {
+		return err
+	}
+
+	dirs := []string{root}
+	if len(scanRootPaths) > 0 {
+		dirs = nil
+		for _, path := range scanRootPaths {
+			dir, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("scan root %s is not a directory", path)
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+
+	switch {
+	case repoRootPath != "":
+		root, err = filepath.Abs(repoRootPath)
+		if err != nil {
+			return err
+		}
+	case len(scanRootPaths) > 0:
+		if found, ok := findGitRoot(dirs[0]); ok {
+			root = found
+		}
+	}
+
+	for _, dir := range dirs {
+		if !isWithin(dir, root) {
+			return fmt.Errorf("scan root %s is outside of the repo root %s", dir, root)
+		}
+	}
+
+	gitRoot = withTrailingSeparator(root)
+	scanRoots = nil
+	for _, dir := range outermostDirs(dirs) {
+		scanRoots = append(scanRoots, withTrailingSeparator(dir))
+	}
+	return nil
+}
+
+// findGitRoot returns the closest directory from `dir` upwards that contains .git
+func findGitRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// outermostDirs returns the sorted unique `dirs` that are not within another one of them, so that modules are
+// only found once
+func outermostDirs(dirs []string) []string {
+	sorted := append([]string{}, dirs...)
+	sort.Strings(sorted)
+
+	outermost := []string{}
+	for _, dir := range sorted {
+		nested := false
+		for _, other := range outermost {
+			if isWithin(dir, other) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			outermost = append(outermost, dir)
+		}
+	}
+	return outermost
+}
+
+// isScanRoot returns whether `dir` is one of the directories modules are looked for in
+func isScanRoot(dir string) bool {
+	for _, scanRoot := range scanRoots {
+		if dir == scanRoot {
+			return true
+		}
+	}
+	return false
+}
+
+// ownsPath returns whether modules at `path` are looked for from `scanRoot`: the scan root that `path` is in,
+// or the first one when it is in none of them
+func ownsPath(scanRoot string, path string) bool {
+	if len(scanRoots) <= 1 {
+		return true
+	}
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range scanRoots {
+		if isWithin(absolutePath, dir) {
+			return dir == withTrailingSeparator(scanRoot)
+		}
+	}
+	return scanRoot == scanRoots[0]
+}
+
+// isWithin returns whether the absolute path `path` is `dir` or below it
+func isWithin(path string, dir string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+func withTrailingSeparator(dir string) string {
+	return strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+}
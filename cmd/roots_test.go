@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRoots(t *testing.T) {
+	defer func(root string, repoRoot string, scanRootsFlag []string) {
+		gitRoot, repoRootPath, scanRootPaths = root, repoRoot, scanRootsFlag
+	}(gitRoot, repoRootPath, scanRootPaths)
+
+	repo := t.TempDir()
+	for _, dir := range []string{".git", "infra/live/prod", "platform/live"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, dir), 0755))
+	}
+	other := t.TempDir()
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		name          string
+		root          string
+		repoRoot      string
+		scanRoots     []string
+		wantRepoRoot  string
+		wantScanRoots []string
+		wantErr       string
+	}{
+		{
+			name:          "root is both",
+			root:          filepath.Join(repo, "infra"),
+			wantRepoRoot:  filepath.Join(repo, "infra") + sep,
+			wantScanRoots: []string{filepath.Join(repo, "infra") + sep},
+		},
+		{
+			name:          "repo root detected from .git",
+			root:          other,
+			scanRoots:     []string{filepath.Join(repo, "infra", "live")},
+			wantRepoRoot:  repo + sep,
+			wantScanRoots: []string{filepath.Join(repo, "infra", "live") + sep},
+		},
+		{
+			name:          "root scanned below the repo root",
+			root:          filepath.Join(repo, "platform"),
+			repoRoot:      repo,
+			wantRepoRoot:  repo + sep,
+			wantScanRoots: []string{filepath.Join(repo, "platform") + sep},
+		},
+		{
+			name:          "nested scan roots are dropped",
+			root:          other,
+			repoRoot:      repo,
+			scanRoots:     []string{filepath.Join(repo, "platform", "live"), filepath.Join(repo, "infra", "live", "prod"), filepath.Join(repo, "infra")},
+			wantRepoRoot:  repo + sep,
+			wantScanRoots: []string{filepath.Join(repo, "infra") + sep, filepath.Join(repo, "platform", "live") + sep},
+		},
+		{
+			name:      "scan root outside of the repo root",
+			root:      other,
+			repoRoot:  filepath.Join(repo, "infra"),
+			scanRoots: []string{filepath.Join(repo, "platform")},
+			wantErr:   "is outside of the repo root",
+		},
+		{
+			name:      "missing scan root",
+			root:      other,
+			scanRoots: []string{filepath.Join(repo, "missing")},
+			wantErr:   "is not a directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitRoot, repoRootPath, scanRootPaths = tt.root, tt.repoRoot, tt.scanRoots
+
+			err := resolveRoots()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRepoRoot, gitRoot)
+			assert.Equal(t, tt.wantScanRoots, scanRoots)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// Key of the config file holding the project rules, next to the flags
+const projectRulesKey = "rules"
+
+// projectRule sets project settings for all projects whose directory matches a glob, optionally only
+// if the locals of the module meet some conditions
+type projectRule struct {
+	// Glob, with `**` support, matched against the project directory relative to the root
+	Match string `json:"match"`
+
+	// Conditions on the resolved locals of the module. All of them have to be met for the rule to apply.
+	When *projectRuleConditions `json:"when,omitempty"`
+
+	Settings projectRuleSettings `json:"settings"`
+}
+
+// projectRuleConditions are compared with the locals of a module. Locals that are not set never match.
+type projectRuleConditions struct {
+	Workflow         *string `json:"workflow,omitempty"`
+	TerraformVersion *string `json:"terraform_version,omitempty"`
+	Autoplan         *bool   `json:"autoplan,omitempty"`
+	Project          *bool   `json:"project,omitempty"`
+}
+
+// projectRuleSettings are the settings a rule applies. They override the flags, and are overridden by locals.
+type projectRuleSettings struct {
+	Workflow          *string   `json:"workflow,omitempty"`
+	TerraformVersion  *string   `json:"terraform_version,omitempty"`
+	Autoplan          *bool     `json:"autoplan,omitempty"`
+	ApplyRequirements *[]string `json:"apply_requirements,omitempty"`
+
+	// Paths or globs relative to the root, which are added to `when_modified`
+	ExtraDependencies []string `json:"extra_dependencies,omitempty"`
+}
+
+// Rules from the config file, in the order they are applied
+var projectRules []projectRule
+
+// decodeProjectRules decodes and validates the `rules` of the config file
+func decodeProjectRules(value interface{}) ([]projectRule, error) {
+	// Round trip through JSON to decode into the typed rules, rejecting unknown keys
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+
+	rules := []projectRule{}
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", projectRulesKey, err)
+	}
+
+	for i, rule := range rules {
+		if rule.Match == "" {
+			return nil, fmt.Errorf("%s[%d]: match is required", projectRulesKey, i)
+		}
+		// doublestar only reports bad patterns once it gets to them, while path.Match checks all of the syntax
+		if _, err := path.Match(rule.Match, ""); err != nil {
+			return nil, fmt.Errorf("%s[%d]: invalid match %q: %w", projectRulesKey, i, rule.Match, err)
+		}
+		if rule.Settings.ApplyRequirements != nil {
+			for _, requirement := range *rule.Settings.ApplyRequirements {
+				if !isValidApplyRequirement(requirement) {
+					return nil, fmt.Errorf("%s[%d]: unknown apply requirement %q, must be one of: %s", projectRulesKey, i, requirement, strings.Join(validApplyRequirements, ", "))
+				}
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// matches returns true if the rule applies to the project in `dir`, relative to the root, with `locals`
+func (r projectRule) matches(dir string, locals ResolvedLocals) bool {
+	// Patterns are validated when decoding, so errors can't happen here
+	if matched, _ := doublestar.Match(r.Match, dir); !matched {
+		return false
+	}
+	if r.When == nil {
+		return true
+	}
+
+	if r.When.Workflow != nil && *r.When.Workflow != locals.AtlantisWorkflow {
+		return false
+	}
+	if r.When.TerraformVersion != nil && *r.When.TerraformVersion != locals.TerraformVersion {
+		return false
+	}
+	if r.When.Autoplan != nil && (locals.AutoPlan == nil || *r.When.Autoplan != *locals.AutoPlan) {
+		return false
+	}
+	if r.When.Project != nil && (locals.markedProject == nil || *r.When.Project != *locals.markedProject) {
+		return false
+	}
+	return true
+}
+
+// applyProjectRules merges the settings of all rules matching the project in `dir` into the locals of the
+// module, with the locals taking precedence. It also returns the absolute paths of the extra dependencies
+// of the matching rules.
+func applyProjectRules(dir string, locals ResolvedLocals) (ResolvedLocals, []string) {
+	fromRules := ResolvedLocals{}
+	extraDependencies := []string{}
+
+	for _, rule := range projectRules {
+		if !rule.matches(dir, locals) {
+			continue
+		}
+
+		settings := rule.Settings
+		if settings.Workflow != nil {
+			fromRules.AtlantisWorkflow = *settings.Workflow
+		}
+		if settings.TerraformVersion != nil {
+			fromRules.TerraformVersion = *settings.TerraformVersion
+		}
+		if settings.Autoplan != nil {
+			autoplan := *settings.Autoplan
+			fromRules.AutoPlan = &autoplan
+		}
+		if settings.ApplyRequirements != nil {
+			fromRules.ApplyRequirements = append([]string{}, *settings.ApplyRequirements...)
+		}
+		for _, dependency := range settings.ExtraDependencies {
+			extraDependencies = append(extraDependencies, filepath.Join(gitRoot, filepath.FromSlash(dependency)))
+		}
+	}
+
+	return mergeResolvedLocals(fromRules, locals), extraDependencies
+}
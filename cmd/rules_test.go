@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Decodes rules the way they are read from the config file
+func decodeTestRules(t *testing.T, content string) ([]projectRule, error) {
+	var value interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(content), &value))
+	return decodeProjectRules(value)
+}
+
+func TestDecodeProjectRules(t *testing.T) {
+	rules, err := decodeTestRules(t, `
+- match: prod/**
+  when:
+    autoplan: true
+  settings:
+    workflow: prod
+    apply_requirements: [approved]
+`)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "prod/**", rules[0].Match)
+	require.NotNil(t, rules[0].When)
+	assert.True(t, *rules[0].When.Autoplan)
+	assert.Equal(t, "prod", *rules[0].Settings.Workflow)
+	assert.Equal(t, []string{"approved"}, *rules[0].Settings.ApplyRequirements)
+
+	errorCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"unknown setting", "- match: a\n  settings:\n    worklfow: x\n", `unknown field "worklfow"`},
+		{"unknown condition", "- match: a\n  when:\n    skip: true\n", `unknown field "skip"`},
+		{"missing match", "- settings:\n    workflow: x\n", "rules[0]: match is required"},
+		{"invalid match", "- match: '['\n", `rules[0]: invalid match "["`},
+		{"invalid requirement", "- match: a\n  settings:\n    apply_requirements: [aproved]\n", `rules[0]: unknown apply requirement "aproved"`},
+		{"wrong type", "- match: a\n  settings:\n    autoplan: yes please\n", "rules:"},
+		{"not a list", "match: a\n", "rules:"},
+	}
+	for _, c := range errorCases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := decodeTestRules(t, c.content)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.expected)
+		})
+	}
+}
+
+func TestApplyProjectRules(t *testing.T) {
+	oldRules, oldRoot := projectRules, gitRoot
+	defer func() { projectRules, gitRoot = oldRules, oldRoot }()
+	gitRoot = "/repo/"
+
+	rules, err := decodeTestRules(t, `
+- match: "**"
+  settings:
+    workflow: everywhere
+    extra_dependencies: [versions.hcl]
+- match: prod/**
+  settings:
+    workflow: prod
+    autoplan: true
+    apply_requirements: [approved]
+- match: prod/**
+  when:
+    workflow: custom
+  settings:
+    terraform_version: 1.5.0
+`)
+	require.NoError(t, err)
+	projectRules = rules
+
+	t.Run("later rules win", func(t *testing.T) {
+		locals, deps := applyProjectRules("prod/app", ResolvedLocals{})
+		assert.Equal(t, "prod", locals.AtlantisWorkflow)
+		require.NotNil(t, locals.AutoPlan)
+		assert.True(t, *locals.AutoPlan)
+		assert.Equal(t, []string{"approved"}, locals.ApplyRequirements)
+		assert.Equal(t, "", locals.TerraformVersion)
+		assert.Equal(t, []string{"/repo/versions.hcl"}, deps)
+	})
+
+	t.Run("locals win over rules", func(t *testing.T) {
+		autoplan := false
+		locals, _ := applyProjectRules("prod/app", ResolvedLocals{
+			AtlantisWorkflow:          "custom",
+			AutoPlan:                  &autoplan,
+			ExtraAtlantisDependencies: []string{"/repo/local.tfvars"},
+		})
+		assert.Equal(t, "custom", locals.AtlantisWorkflow)
+		assert.False(t, *locals.AutoPlan)
+		assert.Equal(t, []string{"approved"}, locals.ApplyRequirements)
+		assert.Equal(t, []string{"/repo/local.tfvars"}, locals.ExtraAtlantisDependencies)
+
+		// The condition on the workflow local is met
+		assert.Equal(t, "1.5.0", locals.TerraformVersion)
+	})
+
+	t.Run("non matching directories", func(t *testing.T) {
+		locals, _ := applyProjectRules("staging/app", ResolvedLocals{})
+		assert.Equal(t, "everywhere", locals.AtlantisWorkflow)
+		assert.Nil(t, locals.AutoPlan)
+	})
+}
+
+func TestProjectRuleConditions(t *testing.T) {
+	yes, no := true, false
+	rule := projectRule{Match: "**", When: &projectRuleConditions{Autoplan: &yes}}
+
+	assert.True(t, rule.matches("a", ResolvedLocals{AutoPlan: &yes}))
+	assert.False(t, rule.matches("a", ResolvedLocals{AutoPlan: &no}))
+	assert.False(t, rule.matches("a", ResolvedLocals{}), "unset locals never match")
+}
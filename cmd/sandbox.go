@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Replace functions that run commands or call cloud APIs by stubs, from `--sandbox`
+var sandbox bool
+
+// Path of the file with the values of the stubs, from `--sandbox-stubs`
+var sandboxStubsPath string
+
+// sandboxStubFile is the content of the `--sandbox-stubs` file. Functions without a value return a placeholder.
+type sandboxStubFile struct {
+	// Output of `run_cmd`, by its arguments joined with spaces, without the `--terragrunt-quiet` and
+	// `--terragrunt-global-cache` options
+	RunCmd map[string]string `json:"run_cmd"`
+
+	// Decrypted content of `sops_decrypt_file`, by the path of the file relative to the root
+	SopsDecryptFile map[string]string `json:"sops_decrypt_file"`
+
+	GetAWSAccountID            *string `json:"get_aws_account_id"`
+	GetAWSAccountAlias         *string `json:"get_aws_account_alias"`
+	GetAWSCallerIdentityArn    *string `json:"get_aws_caller_identity_arn"`
+	GetAWSCallerIdentityUserID *string `json:"get_aws_caller_identity_user_id"`
+}
+
+// Values of the stubs for the current run, set by `readSandboxStubs`
+var sandboxStubs = sandboxStubFile{}
+
+// Values of stubs without one in the `--sandbox-stubs` file
+const (
+	placeholderRunCmd          = "sandbox"
+	placeholderSopsDecryptFile = "{}"
+	placeholderAWSAccountID    = "000000000000"
+	placeholderAWSAccountAlias = "sandbox"
+	placeholderAWSCallerArn    = "arn:aws:iam::000000000000:user/sandbox"
+	placeholderAWSCallerUserID = "SANDBOX"
+)
+
+// Options of `run_cmd` before the command, which don't change its output
+var runCmdOptions = map[string]bool{
+	"--terragrunt-quiet":        true,
+	"--terragrunt-global-cache": true,
+}
+
+// readSandboxStubs reads the `--sandbox-stubs` file at `path`
+func readSandboxStubs(path string) (sandboxStubFile, error) {
+	stubs := sandboxStubFile{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return stubs, err
+	}
+
+	// Decoded as JSON to reject unknown keys
+	encoded, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return stubs, fmt.Errorf("invalid sandbox stubs %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&stubs); err != nil {
+		return stubs, fmt.Errorf("invalid sandbox stubs %s: %w", path, err)
+	}
+
+	return stubs, nil
+}
+
+// stubbedCalls records the calls of stubbed functions, with where they were made: the file:line of their call
+// sites, or the module whose evaluation made them when these are not known
+type stubbedCalls struct {
+	mtx   sync.Mutex
+	calls map[string]map[string]bool
+}
+
+// Calls of stubbed functions during the current run
+var sandboxCalls = newStubbedCalls()
+
+func newStubbedCalls() *stubbedCalls {
+	return &stubbedCalls{calls: map[string]map[string]bool{}}
+}
+
+func (s *stubbedCalls) add(call string, locations []string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.calls[call] == nil {
+		s.calls[call] = map[string]bool{}
+	}
+	for _, location := range locations {
+		s.calls[call][location] = true
+	}
+}
+
+// sorted returns every call, each with the sorted locations it was made at
+func (s *stubbedCalls) sorted() [][2]string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	calls := make([][2]string, 0, len(s.calls))
+	for call, paths := range s.calls {
+		sortedPaths := make([]string, 0, len(paths))
+		for path := range paths {
+			sortedPaths = append(sortedPaths, path)
+		}
+		sort.Strings(sortedPaths)
+		calls = append(calls, [2]string{call, strings.Join(sortedPaths, ", ")})
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i][0] < calls[j][0] })
+	return calls
+}
+
+// reportSandboxCalls logs every call that was answered by a stub
+func reportSandboxCalls() {
+	for _, call := range sandboxCalls.sorted() {
+		log.Infof("Stubbed %s at %s", call[0], call[1])
+	}
+}
+
+// callSites returns the file:line of the calls of the function `name` in the config at `configPath` that can
+// have been made with `args`. Arguments only known while evaluating can match any value.
+func callSites(configPath string, name string, args []string) []string {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil
+	}
+	file, diags := hclsyntax.ParseConfig(content, configPath, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	sites := []string{}
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		call, ok := node.(*hclsyntax.FunctionCallExpr)
+		if ok && call.Name == name && callMatches(call, args) {
+			sites = append(sites, fmt.Sprintf("%s:%d", relativeToRoot(configPath), call.NameRange.Start.Line))
+		}
+		return nil
+	})
+	return sites
+}
+
+// callMatches returns whether the arguments of `call` can evaluate to `args`
+func callMatches(call *hclsyntax.FunctionCallExpr, args []string) bool {
+	if call.ExpandFinal {
+		return true
+	}
+	if len(call.Args) != len(args) {
+		return false
+	}
+	for i, expr := range call.Args {
+		value, diags := expr.Value(nil)
+		if diags.HasErrors() || !value.IsWhollyKnown() || !value.Type().Equals(cty.String) {
+			continue
+		}
+		if value.AsString() != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stubbedCallLocations returns where the call of the function `name` with `args` was made while evaluating the
+// module at `configPath`. Calls in the files it includes or reads are only known by the module.
+func stubbedCallLocations(configPath string, name string, args []string) []string {
+	if sites := callSites(configPath, name, args); len(sites) > 0 {
+		return sites
+	}
+	return []string{relativeToRoot(configPath)}
+}
+
+// sandboxFunctions returns the stubs replacing the terragrunt functions that run commands or call cloud APIs,
+// for evaluating the module at `configPath`
+func sandboxFunctions(configPath string) map[string]function.Function {
+	return map[string]function.Function{
+		config.FuncNameRunCmd: stubFunction(config.FuncNameRunCmd, configPath, func(args []string) (string, error) {
+			for len(args) > 0 && runCmdOptions[args[0]] {
+				args = args[1:]
+			}
+			if len(args) == 0 {
+				return "", fmt.Errorf("run_cmd needs a command")
+			}
+			if value, ok := sandboxStubs.RunCmd[strings.Join(args, " ")]; ok {
+				return value, nil
+			}
+			return placeholderRunCmd, nil
+		}),
+		config.FuncNameSopsDecryptFile: stubFunction(config.FuncNameSopsDecryptFile, configPath, func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("sops_decrypt_file needs exactly one parameter, got %d", len(args))
+			}
+			path := args[0]
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(configPath), path)
+			}
+			if value, ok := sandboxStubs.SopsDecryptFile[relativeToRoot(path)]; ok {
+				return value, nil
+			}
+			return placeholderSopsDecryptFile, nil
+		}),
+		config.FuncNameGetAWSAccountID:            stubValueFunction(config.FuncNameGetAWSAccountID, configPath, sandboxStubs.GetAWSAccountID, placeholderAWSAccountID),
+		config.FuncNameGetAWSAccountAlias:         stubValueFunction(config.FuncNameGetAWSAccountAlias, configPath, sandboxStubs.GetAWSAccountAlias, placeholderAWSAccountAlias),
+		config.FuncNameGetAWSCallerIdentityArn:    stubValueFunction(config.FuncNameGetAWSCallerIdentityArn, configPath, sandboxStubs.GetAWSCallerIdentityArn, placeholderAWSCallerArn),
+		config.FuncNameGetAWSCallerIdentityUserID: stubValueFunction(config.FuncNameGetAWSCallerIdentityUserID, configPath, sandboxStubs.GetAWSCallerIdentityUserID, placeholderAWSCallerUserID),
+	}
+}
+
+// stubFunction returns a function of strings to a string, which records every call of it while evaluating the
+// module at `configPath`
+func stubFunction(name string, configPath string, stub func(args []string) (string, error)) function.Function {
+	return function.New(&function.Spec{
+		VarParam: &function.Parameter{Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			params := make([]string, len(args))
+			quoted := make([]string, len(args))
+			for i, arg := range args {
+				params[i] = arg.AsString()
+				quoted[i] = strconv.Quote(params[i])
+			}
+
+			sandboxCalls.add(name+"("+strings.Join(quoted, ", ")+")", stubbedCallLocations(configPath, name, params))
+			value, err := stub(params)
+			if err != nil {
+				return cty.StringVal(""), err
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// stubValueFunction returns a function without parameters, which returns the value from the stub file or the
+// placeholder, and records every call of it while evaluating the module at `configPath`
+func stubValueFunction(name string, configPath string, value *string, placeholder string) function.Function {
+	return function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			sandboxCalls.add(name+"()", stubbedCallLocations(configPath, name, nil))
+			if value != nil {
+				return cty.StringVal(*value), nil
+			}
+			return cty.StringVal(placeholder), nil
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestReadSandboxStubs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stubs.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("get_aws_account_id: \"123\"\nrun_cmd:\n  echo hi: hi\n"), 0644))
+	stubs, err := readSandboxStubs(path)
+	require.NoError(t, err)
+	require.NotNil(t, stubs.GetAWSAccountID)
+	assert.Equal(t, "123", *stubs.GetAWSAccountID)
+	assert.Nil(t, stubs.GetAWSAccountAlias)
+	assert.Equal(t, map[string]string{"echo hi": "hi"}, stubs.RunCmd)
+
+	require.NoError(t, os.WriteFile(path, []byte("get_aws_acount_id: \"123\"\n"), 0644))
+	_, err = readSandboxStubs(path)
+	assert.ErrorContains(t, err, `unknown field "get_aws_acount_id"`)
+}
+
+func TestSandboxFunctions(t *testing.T) {
+	resetForRun()
+	gitRoot = "/repo/"
+	alias := "prod"
+	sandboxStubs = sandboxStubFile{
+		RunCmd:             map[string]string{"git rev-parse HEAD": "abc"},
+		SopsDecryptFile:    map[string]string{"secrets.json": `{"a": 1}`},
+		GetAWSAccountAlias: &alias,
+	}
+	sandboxCalls = newStubbedCalls()
+	defer func() { sandboxStubs = sandboxStubFile{} }()
+
+	functions := sandboxFunctions("/repo/app/terragrunt.hcl")
+	call := func(name string, args ...string) (string, error) {
+		values := make([]cty.Value, len(args))
+		for i, arg := range args {
+			values[i] = cty.StringVal(arg)
+		}
+		value, err := functions[name].Call(values)
+		if err != nil {
+			return "", err
+		}
+		return value.AsString(), nil
+	}
+
+	cases := []struct {
+		function string
+		args     []string
+		expected string
+	}{
+		{"run_cmd", []string{"--terragrunt-quiet", "git", "rev-parse", "HEAD"}, "abc"},
+		{"run_cmd", []string{"whoami"}, placeholderRunCmd},
+		{"sops_decrypt_file", []string{"../secrets.json"}, `{"a": 1}`},
+		{"sops_decrypt_file", []string{"other.json"}, placeholderSopsDecryptFile},
+		{"get_aws_account_alias", nil, "prod"},
+		{"get_aws_account_id", nil, placeholderAWSAccountID},
+		{"get_aws_caller_identity_arn", nil, placeholderAWSCallerArn},
+		{"get_aws_caller_identity_user_id", nil, placeholderAWSCallerUserID},
+	}
+	for _, c := range cases {
+		value, err := call(c.function, c.args...)
+		require.NoError(t, err, c.function)
+		assert.Equal(t, c.expected, value, c.function)
+	}
+
+	_, err := call("run_cmd", "--terragrunt-quiet")
+	assert.EqualError(t, err, "run_cmd needs a command")
+
+	assert.Contains(t, sandboxCalls.sorted(), [2]string{`run_cmd("whoami")`, "app/terragrunt.hcl"})
+	assert.Contains(t, sandboxCalls.sorted(), [2]string{"get_aws_account_id()", "app/terragrunt.hcl"})
+}
+
+func TestSandboxCallSites(t *testing.T) {
+	resetForRun()
+	root := t.TempDir()
+	gitRoot = root + string(filepath.Separator)
+	sandboxCalls = newStubbedCalls()
+
+	configPath := filepath.Join(root, "app", "terragrunt.hcl")
+	require.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+	require.NoError(t, os.WriteFile(configPath, []byte(`locals {
+  commit  = run_cmd("git", "rev-parse", "HEAD")
+  user    = run_cmd("whoami")
+  account = get_aws_account_id()
+  other   = run_cmd("echo", local.user)
+}
+`), 0644))
+
+	functions := sandboxFunctions(configPath)
+	_, err := functions["run_cmd"].Call([]cty.Value{cty.StringVal("git"), cty.StringVal("rev-parse"), cty.StringVal("HEAD")})
+	require.NoError(t, err)
+	_, err = functions["run_cmd"].Call([]cty.Value{cty.StringVal("whoami")})
+	require.NoError(t, err)
+	_, err = functions["get_aws_account_id"].Call(nil)
+	require.NoError(t, err)
+	_, err = functions["run_cmd"].Call([]cty.Value{cty.StringVal("echo"), cty.StringVal("sandbox")})
+	require.NoError(t, err)
+
+	// Each call is reported at its own line, and calls from other files by their module
+	_, err = functions["run_cmd"].Call([]cty.Value{cty.StringVal("date")})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][2]string{
+		{"get_aws_account_id()", "app/terragrunt.hcl:4"},
+		{`run_cmd("date")`, "app/terragrunt.hcl"},
+		{`run_cmd("echo", "sandbox")`, "app/terragrunt.hcl:5"},
+		{`run_cmd("git", "rev-parse", "HEAD")`, "app/terragrunt.hcl:2"},
+		{`run_cmd("whoami")`, "app/terragrunt.hcl:3"},
+	}, sandboxCalls.sorted())
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Follow symlinked directories while looking for modules and project hcl files, from `--follow-symlinks`
+var followSymlinks bool
+
+// walkDir walks the tree at `root` like filepath.Walk. With `--follow-symlinks`, symlinked directories are walked
+// as if they were regular ones, at their path below `root` rather than at their target, so that the paths are
+// those Atlantis sees in the checkout. Links to a directory that is being walked are skipped, to avoid cycles.
+func walkDir(root string, walkFn filepath.WalkFunc) error {
+	if !followSymlinks {
+		return filepath.Walk(root, walkFn)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = walkFollowingSymlinks(root, info, map[string]bool{}, walkFn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkFollowingSymlinks walks `path`, where `ancestors` are the real paths of the directories above it
+func walkFollowingSymlinks(path string, info os.FileInfo, ancestors map[string]bool, walkFn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return walkFn(path, info, nil)
+	}
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+	if ancestors[realPath] {
+		log.Debugf("Not following %s, as it links to a directory above it", path)
+		return nil
+	}
+
+	if err := walkFn(path, info, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+
+	ancestors[realPath] = true
+	defer delete(ancestors, realPath)
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(path, entry.Name())
+
+		// Links are followed, except broken ones, which are passed on as they are
+		entryInfo, err := os.Stat(entryPath)
+		if err != nil {
+			entryInfo, err = os.Lstat(entryPath)
+		}
+		if err != nil {
+			if err := walkFn(entryPath, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		if err := walkFollowingSymlinks(entryPath, entryInfo, ancestors, walkFn); err != nil {
+			// Like filepath.Walk, skipping from a file skips the rest of its directory
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findModuleConfigFiles returns the terragrunt configs below `rootPath`, like config.FindConfigFilesInPath of
+// terragrunt, but following symlinked directories with walkDir
+func findModuleConfigFiles(rootPath string, opts *options.TerragruntOptions) ([]string, error) {
+	configFiles := []string{}
+
+	err := walkDir(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		// Skip the caches of terragrunt and the data dirs of terraform
+		if info.Name() == util.TerragruntCacheDir || info.Name() == opts.TerraformDataDir() {
+			return filepath.SkipDir
+		}
+
+		for _, configFile := range config.DefaultTerragruntConfigPaths {
+			configFile = util.JoinPath(path, configFile)
+			if !util.IsDir(configFile) && util.FileExists(configFile) {
+				configFiles = append(configFiles, configFile)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return configFiles, err
+}
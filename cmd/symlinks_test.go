@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkDir(t *testing.T) {
+	defer func(follow bool) { followSymlinks = follow }(followSymlinks)
+
+	root := t.TempDir()
+	for _, dir := range []string{"live/prod", "shared/app"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "shared", "app", "terragrunt.hcl"), []byte{}, 0644))
+	require.NoError(t, os.Symlink(filepath.Join("..", "..", "shared", "app"), filepath.Join(root, "live", "prod", "app")))
+	require.NoError(t, os.Symlink("..", filepath.Join(root, "live", "prod", "loop")))
+	require.NoError(t, os.Symlink("missing", filepath.Join(root, "live", "broken")))
+
+	walk := func() []string {
+		paths := []string{}
+		require.NoError(t, walkDir(root, func(path string, info os.FileInfo, err error) error {
+			require.NoError(t, err)
+			relative, err := filepath.Rel(root, path)
+			require.NoError(t, err)
+			paths = append(paths, filepath.ToSlash(relative))
+			return nil
+		}))
+		return paths
+	}
+
+	followSymlinks = false
+	assert.Equal(t, []string{".", "live", "live/broken", "live/prod", "live/prod/app", "live/prod/loop", "shared", "shared/app", "shared/app/terragrunt.hcl"}, walk())
+
+	followSymlinks = true
+	assert.Equal(t, []string{".", "live", "live/broken", "live/prod", "live/prod/app", "live/prod/app/terragrunt.hcl", "shared", "shared/app", "shared/app/terragrunt.hcl"}, walk())
+}
+
+func TestWalkDirSkipDir(t *testing.T) {
+	defer func(follow bool) { followSymlinks = follow }(followSymlinks)
+	followSymlinks = true
+
+	root := t.TempDir()
+	for _, dir := range []string{"a/skipped", "b"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+
+	paths := []string{}
+	require.NoError(t, walkDir(root, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		relative, _ := filepath.Rel(root, path)
+		paths = append(paths, filepath.ToSlash(relative))
+		if info.Name() == "a" {
+			return filepath.SkipDir
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{".", "a", "b"}, paths)
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/gruntwork-io/terragrunt/pkg/log/format"
 	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 )
 
 const (
@@ -67,13 +69,75 @@ func createLogger() log.Logger {
 	return log.New(log.WithLevel(log.ErrorLevel), log.WithFormatter(formatter))
 }
 
+// predefinedFunctions returns the functions that replace those of terragrunt when evaluating the module of `ctx`
+// at `configPath` with the environment variables `env`
+func predefinedFunctions(ctx *config.ParsingContext, env map[string]string, configPath string) map[string]function.Function {
+	functions := map[string]function.Function{
+		config.FuncNameGetEnv:               trackingGetEnv(env, configPath),
+		config.FuncNameReadTerragruntConfig: recordingReadTerragruntConfig(ctx),
+	}
+	for name, fn := range repoRootFunctions(ctx.TerragruntOptions) {
+		functions[name] = fn
+	}
+	if sandbox {
+		for name, stub := range sandboxFunctions(configPath) {
+			functions[name] = stub
+		}
+	}
+	return functions
+}
+
+// repoRootFunctions returns the functions of terragrunt about the root of the repo, resolving to `--root` instead
+// of asking git, so that they work without a .git directory and agree with the paths in the output. Paths are
+// relative to the working directory of `opts`, like in terragrunt.
+func repoRootFunctions(opts *options.TerragruntOptions) map[string]function.Function {
+	repoRootFunction := func(resolve func(root string) (string, error)) function.Function {
+		return function.New(&function.Spec{
+			Type: function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+				value, err := resolve(filepath.Clean(gitRoot))
+				if err != nil {
+					return cty.StringVal(""), err
+				}
+				return cty.StringVal(filepath.ToSlash(value)), nil
+			},
+		})
+	}
+
+	return map[string]function.Function{
+		config.FuncNameGetRepoRoot: repoRootFunction(func(root string) (string, error) {
+			return root, nil
+		}),
+		config.FuncNameGetPathFromRepoRoot: repoRootFunction(func(root string) (string, error) {
+			return filepath.Rel(root, opts.WorkingDir)
+		}),
+		config.FuncNameGetPathToRepoRoot: repoRootFunction(func(root string) (string, error) {
+			return filepath.Rel(opts.WorkingDir, root)
+		}),
+	}
+}
+
 func NewParsingContextWithConfigPath(ctx context.Context, terragruntConfigPath string) (*TerragruntParsingContext, error) {
 	opt, err := options.NewTerragruntOptionsWithConfigPath(terragruntConfigPath)
 	if err != nil {
 		return nil, err
 	}
 	opt.OriginalTerragruntConfigPath = terragruntConfigPath
-	opt.Env = parseEnvironmentVariables()
+	if evalEnv != nil {
+		opt.Env = make(map[string]string, len(evalEnv))
+		for name, value := range evalEnv {
+			opt.Env[name] = value
+		}
+	} else {
+		opt.Env = parseEnvironmentVariables()
+	}
+
+	// With `--env-matrix`, the variables of the environment being generated override those of the process
+	if env := matrixEnvFromContext(ctx); env != nil {
+		for name, value := range env.Env {
+			opt.Env[name] = value
+		}
+	}
 
 	logger := createLogger()
 
@@ -81,6 +145,7 @@ func NewParsingContextWithConfigPath(ctx context.Context, terragruntConfigPath s
 	ctx = log.ContextWithLogger(ctx, logger)
 
 	parsingContext := config.NewParsingContext(ctx, logger, opt)
+	parsingContext.PredefinedFunctions = predefinedFunctions(parsingContext, opt.Env, terragruntConfigPath)
 
 	terragruntParsingContext := TerragruntParsingContext{
 		Context:        ctx,
@@ -98,7 +163,9 @@ func NewParsingContextWithDecodeList(ctx *TerragruntParsingContext) *TerragruntP
 	contextWithLogger := log.ContextWithLogger(ctx.ParsingContext.Context, logger)
 
 	// Parse the HCL file
-	parseCtx := config.NewParsingContext(contextWithLogger, logger, ctx.ParsingContext.TerragruntOptions).
+	parseCtx := config.NewParsingContext(contextWithLogger, logger, ctx.ParsingContext.TerragruntOptions)
+	parseCtx.PredefinedFunctions = ctx.ParsingContext.PredefinedFunctions
+	parseCtx = parseCtx.
 		WithDecodeList(
 			config.DependencyBlock,
 			config.TerraformBlock,
@@ -153,6 +220,7 @@ func (ctx TerragruntParsingContext) WithDependencyPath(path string) *TerragruntP
 	contextWithLogger := log.ContextWithLogger(ctx.Context, logger)
 
 	terrContext := config.NewParsingContext(contextWithLogger, logger, terrOpts)
+	terrContext.PredefinedFunctions = predefinedFunctions(terrContext, terrOpts.Env, path)
 
 	terragruntParsingContext := TerragruntParsingContext{
 		Context:        ctx.Context,
@@ -182,7 +250,7 @@ func (ctx TerragruntParsingContext) DecodeBaseBlocks(path string, includeFromChi
 func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]string, error) {
 	configFiles := []string{}
 
-	walkFunc := filepath.Walk
+	walkFunc := walkDir
 
 	err := walkFunc(rootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -207,7 +275,11 @@ func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]
 		return nil
 	})
 
-	nestedConfigFiles, err := config.FindConfigFilesInPath(rootPath, opts)
+	findNestedConfigFiles := config.FindConfigFilesInPath
+	if followSymlinks {
+		findNestedConfigFiles = findModuleConfigFiles
+	}
+	nestedConfigFiles, err := findNestedConfigFiles(rootPath, opts)
 	if err == nil {
 		configFiles = append(configFiles, nestedConfigFiles...)
 	}
@@ -216,27 +288,22 @@ func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]
 
 // Finds the absolute paths of all terragrunt.hcl files
 func getAllTerragruntFiles(path string) ([]string, error) {
-	terragruntOptions, err := options.NewTerragruntOptionsWithConfigPath(path)
+	// If filterPaths is provided, override workingPath instead of gitRoot
+	// We do this here because we want to keep the relative path structure of Terragrunt files
+	// to root and just ignore the ConfigFiles
+	workingPaths, err := filterWorkingPaths(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// If filterPaths is provided, override workingPath instead of gitRoot
-	// We do this here because we want to keep the relative path structure of Terragrunt files
-	// to root and just ignore the ConfigFiles
-	workingPaths := []string{path}
-
-	// filters are not working (yet) if using project hcl files (which are kind of filters by themselves)
-	if len(filterPaths) > 0 && len(projectHclFiles) == 0 {
-		workingPaths = []string{}
-		for _, filterPath := range filterPaths {
-			// get all matching folders
-			theseWorkingPaths, err := filepath.Glob(filterPath)
-			if err != nil {
-				return nil, err
-			}
-			workingPaths = append(workingPaths, theseWorkingPaths...)
-		}
+	return findTerragruntFiles(path, workingPaths)
+}
+
+// Finds the absolute paths of all terragrunt.hcl files in `workingPaths`, with the options of `path`
+func findTerragruntFiles(path string, workingPaths []string) ([]string, error) {
+	terragruntOptions, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		return nil, err
 	}
 
 	uniqueConfigFilePaths := make(map[string]bool)
@@ -223,3 +223,24 @@ func TestWithDependencyPath(t *testing.T) {
 	// Verify the context is returned
 	assert.NotNil(t, result)
 }
+
+func TestRepoRootFunctionsResolveToRoot(t *testing.T) {
+	defer func(root string) { gitRoot = root }(gitRoot)
+	gitRoot = "/repo/"
+
+	ctx, err := NewParsingContextWithConfigPath(context.Background(), "/repo/live/vpc/terragrunt.hcl")
+	require.NoError(t, err)
+	ctx.ParsingContext.TerragruntOptions.WorkingDir = "/repo/live/vpc"
+
+	functions := repoRootFunctions(ctx.ParsingContext.TerragruntOptions)
+	expected := map[string]string{
+		"get_repo_root":           "/repo",
+		"get_path_from_repo_root": "live/vpc",
+		"get_path_to_repo_root":   "../..",
+	}
+	for name, want := range expected {
+		value, err := functions[name].Call(nil)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, value.AsString(), name)
+	}
+}
@@ -0,0 +1,15 @@
+locals {
+  atlantis_apply_requirements = ["undiverged"]
+
+  atlantis = {
+    cascade = "none"
+  }
+}
+
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,17 @@
+locals {
+  atlantis = {
+    workflow          = "workflowFromChild"
+    terraform_version = "1.5.0"
+    autoplan = {
+      enabled = false
+    }
+  }
+}
+
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,8 @@
+locals {
+  atlantis = {
+    workflow           = "workflowFromParent"
+    autoplan           = { enabled = true }
+    apply_requirements = ["approved", "mergeable"]
+    extra_dependencies = ["${get_parent_terragrunt_dir()}/shared.tfvars"]
+  }
+}
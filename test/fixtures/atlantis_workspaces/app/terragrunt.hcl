@@ -0,0 +1,18 @@
+locals {
+  atlantis_workspaces = [
+    "staging",
+    {
+      name               = "prod"
+      workflow           = "prod"
+      apply_requirements = ["approved"]
+    },
+  ]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
@@ -0,0 +1,11 @@
+locals {
+  atlantis_cascade = "none"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "app" {
+  config_path = "../app"
+}
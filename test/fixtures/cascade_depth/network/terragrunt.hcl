@@ -0,0 +1,13 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+
+  extra_arguments "network_vars" {
+    commands = ["plan", "apply"]
+
+    required_var_files = ["network.tfvars"]
+  }
+}
+
+dependency "base" {
+  config_path = "../base"
+}
@@ -0,0 +1,9 @@
+locals {
+  atlantis_workflow          = get_env("TG_WORKFLOW", "default")
+  atlantis_terraform_version = get_env("TG_TERRAFORM_VERSION", "0.13.0")
+  atlantis_autoplan          = get_env("TG_AUTOPLAN", "true") == "true"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,14 @@
+locals {
+  env = get_env("TG_ENV", "dev")
+
+  atlantis_autoplan           = local.env == "staging"
+  extra_atlantis_dependencies = ["../config/${local.env}.yaml"]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
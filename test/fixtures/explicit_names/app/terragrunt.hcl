@@ -0,0 +1,13 @@
+locals {
+  atlantis = {
+    project_name = "frontend"
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
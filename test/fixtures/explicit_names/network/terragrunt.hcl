@@ -0,0 +1,8 @@
+locals {
+  atlantis_project_name = "shared-network"
+  atlantis_workspace    = "network"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
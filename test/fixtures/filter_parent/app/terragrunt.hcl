@@ -0,0 +1,7 @@
+include {
+  path = "../parent/terragrunt.hcl"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
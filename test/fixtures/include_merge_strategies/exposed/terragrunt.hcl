@@ -0,0 +1,13 @@
+include "root" {
+  path           = find_in_parent_folders("root.hcl")
+  expose         = true
+  merge_strategy = "no_merge"
+}
+
+locals {
+  atlantis_workflow = include.root.locals.exposed_workflow
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,7 @@
+locals {
+  atlantis_workflow           = "root"
+  atlantis_apply_requirements = ["approved"]
+  extra_atlantis_dependencies = ["${get_parent_terragrunt_dir()}/root.yaml"]
+
+  exposed_workflow = "exposed"
+}
@@ -0,0 +1,6 @@
+locals {
+  region = "eu-west-1"
+
+  atlantis_terraform_version = "1.5.7"
+  atlantis_workflow          = "regional"
+}
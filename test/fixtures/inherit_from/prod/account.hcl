@@ -0,0 +1,6 @@
+locals {
+  account = "prod"
+
+  atlantis_workflow           = "prod"
+  atlantis_apply_requirements = ["approved", "mergeable"]
+}
@@ -0,0 +1,12 @@
+locals {
+  account = read_terragrunt_config(find_in_parent_folders("account.hcl"))
+  region  = read_terragrunt_config("../../common/region.hcl")
+
+  # Later configs take precedence, and the own locals of the module over both
+  atlantis_inherit_from = [local.region, local.account]
+  atlantis_autoplan     = true
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,10 @@
+locals {
+  account = read_terragrunt_config(find_in_parent_folders("account.hcl"))
+
+  atlantis_inherit_from = [local.account]
+  atlantis_workflow     = "db"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-rds?ref=v0.0.1"
+}
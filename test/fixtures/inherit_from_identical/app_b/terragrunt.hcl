@@ -0,0 +1,10 @@
+locals {
+  settings = read_terragrunt_config("../settings/b.hcl")
+
+  # Both settings files have the same locals, but only the one read here is a dependency
+  atlantis_inherit_from = [local.settings]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,11 @@
+locals {
+  settings = read_terragrunt_config("../settings/a.hcl")
+  unused   = read_terragrunt_config("../settings/b.hcl")
+
+  # Only the settings inherited from are a dependency, although b.hcl has the same locals
+  atlantis_inherit_from = [local.settings]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,4 @@
+locals {
+  account_name = "acme"
+  atlantis_workflow = "default"
+}
@@ -0,0 +1,13 @@
+include "account" {
+  path = find_in_parent_folders("account.hcl")
+}
+
+locals {
+  env    = "prod"
+  region = "us-east-1"
+  cidrs  = ["10.0.0.0/16"]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,13 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+# Shared with the modules of the nested stack, which don't include it
+include "service" {
+  path = "../service/shared.hcl"
+}
+
+# Monitors the app of the nested stack
+dependency "app" {
+  config_path = "../service/app"
+}
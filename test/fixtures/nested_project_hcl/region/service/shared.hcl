@@ -0,0 +1,3 @@
+inputs = {
+  alarm_topic = "service-alarms"
+}
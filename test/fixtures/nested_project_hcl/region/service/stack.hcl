@@ -0,0 +1 @@
+locals {}
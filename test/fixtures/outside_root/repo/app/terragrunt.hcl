@@ -0,0 +1,12 @@
+terraform {
+  source = "../../shared/modules/network"
+
+  extra_arguments "common" {
+    commands           = ["plan", "apply"]
+    required_var_files = ["${get_terragrunt_dir()}/../../shared/common.tfvars"]
+  }
+}
+
+dependency "vpc" {
+  config_path = "../../shared/vpc"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-rds?ref=v0.0.1"
+}
+
+dependency "app" {
+  config_path = "../app"
+}
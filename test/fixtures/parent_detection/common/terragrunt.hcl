@@ -0,0 +1,4 @@
+# Only included by modules in other repos
+locals {
+  atlantis_parent = true
+}
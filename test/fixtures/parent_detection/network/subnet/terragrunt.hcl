@@ -0,0 +1,3 @@
+include "network" {
+  path = find_in_parent_folders("terragrunt.hcl")
+}
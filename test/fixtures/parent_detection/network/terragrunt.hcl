@@ -0,0 +1,7 @@
+locals {
+  atlantis_parent = false
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-vpc?ref=v0.0.1"
+}
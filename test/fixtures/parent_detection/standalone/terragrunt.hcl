@@ -0,0 +1,4 @@
+# The terraform code of this module is in the same directory, so there is neither a source nor an include
+inputs = {
+  name = "standalone"
+}
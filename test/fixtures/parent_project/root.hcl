@@ -0,0 +1,12 @@
+remote_state {
+  backend = "s3"
+  config = {
+    bucket = "terraform-state"
+    key    = "${path_relative_to_include()}/terraform.tfstate"
+    region = "us-east-1"
+  }
+}
+
+locals {
+  atlantis_workflow = "children"
+}
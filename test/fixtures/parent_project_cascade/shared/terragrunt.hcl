@@ -0,0 +1,4 @@
+# Neither includes another config nor defines a source, so cascading stops here
+dependency "vpc" {
+  config_path = "../vpc"
+}
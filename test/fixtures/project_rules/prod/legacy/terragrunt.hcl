@@ -0,0 +1,7 @@
+locals {
+  atlantis_terraform_version = "0.12.31"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1 @@
+terraform_version = "1.5.0"
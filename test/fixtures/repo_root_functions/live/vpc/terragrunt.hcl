@@ -0,0 +1,8 @@
+locals {
+  atlantis_workflow           = replace(get_path_from_repo_root(), "/", "-")
+  extra_atlantis_dependencies = ["${get_path_to_repo_root()}/config.yaml"]
+}
+
+terraform {
+  source = "${get_repo_root()}/modules/vpc"
+}
@@ -0,0 +1,3 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-route53?ref=v0.0.1"
+}
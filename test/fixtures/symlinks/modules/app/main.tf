@@ -0,0 +1 @@
+variable "vpc_id" {}
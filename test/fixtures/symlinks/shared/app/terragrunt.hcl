@@ -0,0 +1,7 @@
+terraform {
+  source = "${get_repo_root()}/modules/app"
+}
+
+dependency "vpc" {
+  config_path = "../vpc"
+}
@@ -0,0 +1,7 @@
+locals {
+  atlantis_workflow = local.undefined_workflow
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,12 @@
+locals {
+  account_id = get_aws_account_id()
+  secrets    = jsondecode(sops_decrypt_file("secrets.enc.json"))
+
+  atlantis_workflow           = "deploy-${local.account_id}"
+  atlantis_terraform_version  = run_cmd("--terragrunt-quiet", "cat", ".terraform-version")
+  extra_atlantis_dependencies = [lookup(local.secrets, "config", "default.yaml")]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
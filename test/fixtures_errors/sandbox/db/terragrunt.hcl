@@ -0,0 +1,7 @@
+locals {
+  atlantis_workflow = "${get_aws_account_alias()}-${run_cmd("git", "rev-parse", "HEAD")}"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-rds?ref=v0.0.1"
+}